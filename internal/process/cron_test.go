@@ -0,0 +1,76 @@
+package process
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseScheduleErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{"@every 500ms", "shorter than one second"},
+		{"@every soon", "invalid interval"},
+		{"@sometimes", "unknown schedule descriptor"},
+		{"* * * *", "expected 5 fields"},
+		{"60 * * * *", "minute: \"60\" out of range 0-59"},
+		{"* 24 * * *", "hour:"},
+		{"* * 0 * *", "day of month:"},
+		{"* * * 13 *", "month:"},
+		{"* * * * 8", "day of week:"},
+		{"*/0 * * * *", "invalid step"},
+		{"5-1 * * * *", "out of range"},
+		{"x * * * *", "invalid value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseSchedule(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseSchedule(%q) error = %v, want %q", tt.expr, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// a Wednesday
+	from := time.Date(2025, time.January, 15, 10, 30, 45, 0, time.UTC)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2025, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"@every 90s", from.Add(90 * time.Second)},
+		{"* * * * *", at(time.January, 15, 10, 31)},
+		{"*/15 * * * *", at(time.January, 15, 10, 45)},
+		{"0 * * * *", at(time.January, 15, 11, 0)},
+		{"@hourly", at(time.January, 15, 11, 0)},
+		{"30 10 * * *", at(time.January, 16, 10, 30)},
+		{"@daily", at(time.January, 16, 0, 0)},
+		{"0 9-17/4 * * *", at(time.January, 15, 13, 0)},
+		{"0 0 * * mon", at(time.January, 20, 0, 0)},
+		{"0 0 * * 7", at(time.January, 19, 0, 0)},
+		{"0 0 * * sun", at(time.January, 19, 0, 0)},
+		{"0 0 1 * *", at(time.February, 1, 0, 0)},
+		{"0 0 1 jul *", at(time.July, 1, 0, 0)},
+		{"0 0 29 feb *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		// either day field matches when both are restricted
+		{"0 0 1 * fri", at(time.January, 17, 0, 0)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := ParseSchedule(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseSchedule(%q) error = %v", tt.expr, err)
+			}
+			if got := s.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", from, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package process
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ConditionStarted = "started"
+	ConditionHealthy = "healthy"
+
+	dependencyTimeout = 60 * time.Second
+	stopTimeout       = 10 * time.Second
+)
+
+type Dependency struct {
+	Name      string
+	Condition string
+}
+
+func (d Dependency) validate() error {
+	switch d.Condition {
+	case "", ConditionStarted, ConditionHealthy:
+		return nil
+	}
+	return fmt.Errorf("unknown dependency condition %q for %q (expected started|healthy)", d.Condition, d.Name)
+}
+
+func (d Dependency) satisfiedBy(pi *ProcessInformation) bool {
+	if pi.Status != "running" {
+		return false
+	}
+	if d.Condition == ConditionHealthy {
+		return pi.Healthy
+	}
+	return true
+}
+
+// startOrder returns the names of specs sorted so that every process comes
+// after the processes it depends on. It fails on unknown dependencies and
+// on cycles.
+func startOrder(specs []ProcessSpec) ([]string, error) {
+	byName := make(map[string]ProcessSpec, len(specs))
+	names := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		byName[spec.Name] = spec
+		names = append(names, spec.Name)
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(specs))
+	order := make([]string, 0, len(specs))
+	var path []string
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			start := 0
+			for i, n := range path {
+				if n == name {
+					start = i
+				}
+			}
+			cycle := append(append([]string{}, path[start:]...), name)
+			return fmt.Errorf("dependency cycle detected: %s", strings.Join(cycle, " -> "))
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range byName[name].DependsOn {
+			if err := dep.validate(); err != nil {
+				return err
+			}
+			if _, ok := byName[dep.Name]; !ok {
+				return fmt.Errorf("process %q depends on unknown process %q", name, dep.Name)
+			}
+			if err := visit(dep.Name); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		order = append(order, name)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// waitForDependencies blocks until every dependency of pi meets its
+// condition, pi is stopped, or dependencyTimeout passes.
+func (pm *ProcessManager) waitForDependencies(pi *ProcessInformation) error {
+	deadline := time.Now().Add(dependencyTimeout)
+	for {
+		pm.mu.Lock()
+		if pi.stopRequested {
+			pm.mu.Unlock()
+			return fmt.Errorf("stopped while waiting for dependencies")
+		}
+		var pending []string
+		for _, dep := range pi.Spec.DependsOn {
+			members := pm.membersLocked(dep.Name)
+			satisfied := len(members) > 0
+			for _, target := range members {
+				satisfied = satisfied && dep.satisfiedBy(target)
+			}
+			if !satisfied {
+				pending = append(pending, dep.Name)
+			}
+		}
+		if len(pending) == 0 {
+			pi.Status = "starting"
+		}
+		pm.mu.Unlock()
+
+		if len(pending) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for dependencies: %s", strings.Join(pending, ", "))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,102 @@
+package process
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func deps(names ...string) []Dependency {
+	var out []Dependency
+	for _, name := range names {
+		out = append(out, Dependency{Name: name})
+	}
+	return out
+}
+
+func TestStartOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		specs []ProcessSpec
+		want  []string
+		err   string
+	}{
+		{
+			name:  "no dependencies sorted by name",
+			specs: []ProcessSpec{{Name: "c"}, {Name: "a"}, {Name: "b"}},
+			want:  []string{"a", "b", "c"},
+		},
+		{
+			name: "dependencies first, in the order given",
+			specs: []ProcessSpec{
+				{Name: "api", DependsOn: deps("db", "cache")},
+				{Name: "cache"},
+				{Name: "db"},
+				{Name: "web", DependsOn: deps("api")},
+			},
+			want: []string{"db", "cache", "api", "web"},
+		},
+		{
+			name: "shared dependency listed once",
+			specs: []ProcessSpec{
+				{Name: "a", DependsOn: deps("db")},
+				{Name: "b", DependsOn: deps("db")},
+				{Name: "db"},
+			},
+			want: []string{"db", "a", "b"},
+		},
+		{
+			name:  "unknown dependency",
+			specs: []ProcessSpec{{Name: "api", DependsOn: deps("db")}},
+			err:   `process "api" depends on unknown process "db"`,
+		},
+		{
+			name:  "self dependency",
+			specs: []ProcessSpec{{Name: "a", DependsOn: deps("a")}},
+			err:   "dependency cycle detected: a -> a",
+		},
+		{
+			name: "cycle",
+			specs: []ProcessSpec{
+				{Name: "a", DependsOn: deps("b")},
+				{Name: "b", DependsOn: deps("c")},
+				{Name: "c", DependsOn: deps("a")},
+			},
+			err: "dependency cycle detected: a -> b -> c -> a",
+		},
+		{
+			name: "cycle behind a root",
+			specs: []ProcessSpec{
+				{Name: "a", DependsOn: deps("b")},
+				{Name: "b", DependsOn: deps("c")},
+				{Name: "c", DependsOn: deps("b")},
+			},
+			err: "dependency cycle detected: b -> c -> b",
+		},
+		{
+			name: "invalid condition",
+			specs: []ProcessSpec{
+				{Name: "a", DependsOn: []Dependency{{Name: "b", Condition: "ready"}}},
+				{Name: "b"},
+			},
+			err: `unknown dependency condition "ready"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := startOrder(tt.specs)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("startOrder() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("startOrder() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("startOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package process
+
+import "fmt"
+
+// historySize is the number of exits and health check results kept per
+// process.
+const historySize = 10
+
+func appendHistory[T any](history []T, item T) []T {
+	if len(history) >= historySize {
+		history = append(history[:0:0], history[1:]...)
+	}
+	return append(history, item)
+}
+
+// Description is a snapshot of everything known about one process.
+type Description struct {
+	Process ProcessInformation
+	// Exits and Health are the most recent exits and health check results,
+	// oldest first.
+	Exits  []ExitStatus
+	Health []HealthResult
+	// Logs is the tail of the process's output.
+	Logs []string
+}
+
+// Describe returns a snapshot of the process called name with the last
+// logLines lines of its output.
+func (pm *ProcessManager) Describe(name string, logLines int) (*Description, error) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pi, ok := pm.processes[name]
+	if !ok {
+		if members := pm.membersLocked(name); len(members) > 0 {
+			return nil, fmt.Errorf("%q has several instances, name one of them, e.g. %s", name, members[0].Name)
+		}
+		return nil, fmt.Errorf("process %q not found", name)
+	}
+
+	d := &Description{
+		Process: *pi,
+		Exits:   append([]ExitStatus(nil), pi.exits...),
+		Health:  append([]HealthResult(nil), pi.health...),
+	}
+	if logs, ok := pm.logs[name]; ok {
+		lines := logs.Lines()
+		if logLines >= 0 && len(lines) > logLines {
+			lines = lines[len(lines)-logLines:]
+		}
+		d.Logs = lines
+	}
+	return d, nil
+}
@@ -0,0 +1,74 @@
+package process
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const eventLogSize = 1000
+
+// Event is a lifecycle transition of a managed process.
+type Event struct {
+	Time    time.Time
+	Process string
+	Type    string
+	Message string
+}
+
+// EventLog keeps the most recent events and fans new ones out to followers.
+type EventLog struct {
+	mu     sync.Mutex
+	events []Event
+	subs   map[chan Event]struct{}
+}
+
+func NewEventLog() *EventLog {
+	return &EventLog{subs: make(map[chan Event]struct{})}
+}
+
+func (l *EventLog) Record(process, eventType, format string, args ...any) {
+	e := Event{
+		Time:    time.Now(),
+		Process: process,
+		Type:    eventType,
+		Message: fmt.Sprintf(format, args...),
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.events) >= eventLogSize {
+		l.events = l.events[1:]
+	}
+	l.events = append(l.events, e)
+	for ch := range l.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns the recorded events and a channel receiving new ones
+// until cancel is called.
+func (l *EventLog) Subscribe() ([]Event, <-chan Event, func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ch := make(chan Event, 100)
+	l.subs[ch] = struct{}{}
+	cancel := func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.subs[ch]; ok {
+			delete(l.subs, ch)
+			close(ch)
+		}
+	}
+	return append([]Event(nil), l.events...), ch, cancel
+}
+
+func (pm *ProcessManager) Events() *EventLog {
+	return pm.events
+}
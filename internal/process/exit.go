@@ -0,0 +1,96 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Exit reasons tell why the last run of a process ended.
+const (
+	ExitReasonExited            = "exited"
+	ExitReasonSignaled          = "signaled"
+	ExitReasonOOMKilled         = "oom-killed"
+	ExitReasonStoppedByUser     = "stopped-by-user"
+	ExitReasonHealthCheckFailed = "health-check-failed"
+	// restarts by the daemon, asked for or on its own
+	ExitReasonRestarted         = "restarted"
+	ExitReasonThresholdExceeded = "threshold-exceeded"
+	ExitReasonFileChanged       = "file-changed"
+)
+
+// ExitStatus describes how the last run of a process ended.
+type ExitStatus struct {
+	Time   time.Time
+	Reason string
+	// Code is the exit code, or -1 if the process was killed by a signal.
+	Code int
+	// Signal is the name of the signal that killed the process, if any.
+	Signal string
+}
+
+// detail returns the exit code or signal without the reason.
+func (e ExitStatus) detail() string {
+	if e.Signal != "" {
+		return "signal " + e.Signal
+	}
+	return fmt.Sprintf("exit code %d", e.Code)
+}
+
+// exitStatus decodes the wait status of a finished command. killReason, if
+// set, is the reason the daemon itself stopped or killed the process for,
+// which takes precedence over a stop request, and oomKilled tells whether
+// the OOM killer fired in its cgroup meanwhile.
+func exitStatus(state *os.ProcessState, stopRequested bool, killReason string, oomKilled bool) ExitStatus {
+	status := ExitStatus{Time: time.Now(), Reason: ExitReasonExited, Code: -1}
+	if state == nil {
+		return status
+	}
+	status.Code = state.ExitCode()
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		status.Reason = ExitReasonSignaled
+		status.Signal = signalName(ws.Signal())
+	}
+
+	switch {
+	case killReason != "":
+		status.Reason = killReason
+	case stopRequested:
+		status.Reason = ExitReasonStoppedByUser
+	case oomKilled && status.Signal == "SIGKILL":
+		status.Reason = ExitReasonOOMKilled
+	}
+	return status
+}
+
+func signalName(sig syscall.Signal) string {
+	if name := unix.SignalName(sig); name != "" {
+		return name
+	}
+	return strconv.Itoa(int(sig))
+}
+
+// oomKills returns the oom_kill counter from the memory.events file of a
+// cgroup, or 0 if it can't be read.
+func oomKills(cgroup string) uint64 {
+	if cgroup == "" {
+		return 0
+	}
+	data, err := os.ReadFile(filepath.Join(cgroup, "memory.events"))
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "oom_kill "); ok {
+			n, _ := strconv.ParseUint(value, 10, 64)
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,116 @@
+package process
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// runState runs a shell script and returns how it ended.
+func runState(t *testing.T, script string) *os.ProcessState {
+	t.Helper()
+	cmd := exec.Command("sh", "-c", script)
+	cmd.Run()
+	if cmd.ProcessState == nil {
+		t.Fatalf("running %q failed", script)
+	}
+	return cmd.ProcessState
+}
+
+func TestExitStatus(t *testing.T) {
+	exited := runState(t, "exit 3")
+	killed := runState(t, "kill -KILL $$")
+	terminated := runState(t, "kill -TERM $$")
+
+	tests := []struct {
+		name          string
+		state         *os.ProcessState
+		stopRequested bool
+		killReason    string
+		oomKilled     bool
+		want          ExitStatus
+	}{
+		{
+			name: "no state",
+			want: ExitStatus{Reason: ExitReasonExited, Code: -1},
+		},
+		{
+			name:  "exit code",
+			state: exited,
+			want:  ExitStatus{Reason: ExitReasonExited, Code: 3},
+		},
+		{
+			name:  "signal",
+			state: terminated,
+			want:  ExitStatus{Reason: ExitReasonSignaled, Code: -1, Signal: "SIGTERM"},
+		},
+		{
+			name:          "stop request",
+			state:         terminated,
+			stopRequested: true,
+			want:          ExitStatus{Reason: ExitReasonStoppedByUser, Code: -1, Signal: "SIGTERM"},
+		},
+		{
+			name:          "kill reason over stop request",
+			state:         terminated,
+			stopRequested: true,
+			killReason:    ExitReasonRestarted,
+			want:          ExitStatus{Reason: ExitReasonRestarted, Code: -1, Signal: "SIGTERM"},
+		},
+		{
+			name:       "kill reason on exit code",
+			state:      exited,
+			killReason: ExitReasonHealthCheckFailed,
+			want:       ExitStatus{Reason: ExitReasonHealthCheckFailed, Code: 3},
+		},
+		{
+			name:      "oom kill",
+			state:     killed,
+			oomKilled: true,
+			want:      ExitStatus{Reason: ExitReasonOOMKilled, Code: -1, Signal: "SIGKILL"},
+		},
+		{
+			name:      "oom kill in cgroup but not of the process",
+			state:     terminated,
+			oomKilled: true,
+			want:      ExitStatus{Reason: ExitReasonSignaled, Code: -1, Signal: "SIGTERM"},
+		},
+		{
+			name:          "stop request over oom kill",
+			state:         killed,
+			stopRequested: true,
+			oomKilled:     true,
+			want:          ExitStatus{Reason: ExitReasonStoppedByUser, Code: -1, Signal: "SIGKILL"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := exitStatus(tt.state, tt.stopRequested, tt.killReason, tt.oomKilled)
+			if got.Time.IsZero() {
+				t.Error("exitStatus() has no time")
+			}
+			got.Time = tt.want.Time
+			if got != tt.want {
+				t.Errorf("exitStatus() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOOMKills(t *testing.T) {
+	dir := t.TempDir()
+	events := "low 0\nhigh 0\nmax 4\noom 2\noom_kill 2\noom_group_kill 0\n"
+	if err := os.WriteFile(filepath.Join(dir, "memory.events"), []byte(events), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := oomKills(dir); got != 2 {
+		t.Errorf("oomKills() = %d, want 2", got)
+	}
+	if got := oomKills(t.TempDir()); got != 0 {
+		t.Errorf("oomKills() without memory.events = %d, want 0", got)
+	}
+	if got := oomKills(""); got != 0 {
+		t.Errorf("oomKills(\"\") = %d, want 0", got)
+	}
+}
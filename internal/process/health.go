@@ -0,0 +1,95 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+type HealthCheck struct {
+	Command  string
+	Interval time.Duration
+	Timeout  time.Duration
+	Retries  int
+}
+
+func (hc HealthCheck) withDefaults() HealthCheck {
+	if hc.Interval <= 0 {
+		hc.Interval = 5 * time.Second
+	}
+	if hc.Timeout <= 0 {
+		hc.Timeout = hc.Interval
+	}
+	if hc.Retries <= 0 {
+		hc.Retries = 3
+	}
+	return hc
+}
+
+// checkHealth runs the health check of pi every interval until done is
+// closed. The process becomes healthy after the first passing check and
+// unhealthy after Retries consecutive failures. An unhealthy process with a
+// restart policy is terminated so that its supervisor restarts it.
+func (pm *ProcessManager) checkHealth(pi *ProcessInformation, hc HealthCheck, done <-chan struct{}) {
+	hc = hc.withDefaults()
+	ticker := time.NewTicker(hc.Interval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		err := runHealthCheck(hc)
+
+		pm.mu.Lock()
+		result := HealthResult{Time: time.Now(), Healthy: err == nil}
+		if err != nil {
+			result.Message = err.Error()
+		}
+		pi.health = appendHistory(pi.health, result)
+		if err == nil {
+			if !pi.Healthy {
+				fmt.Printf("process %s is healthy\n", pi.Name)
+			}
+			failures = 0
+			pi.Healthy = true
+		} else {
+			failures++
+			if failures >= hc.Retries && pi.Healthy {
+				fmt.Printf("process %s is unhealthy: %v\n", pi.Name, err)
+				pi.Healthy = false
+			}
+			if failures >= hc.Retries && pi.killReason == "" && canRestart(pi.Spec.AutoRestart) &&
+				!pi.stopRequested && pi.Cmd != nil && pi.Cmd.Process != nil {
+				pm.events.Record(pi.Name, "unhealthy", "%d consecutive health checks failed, terminating: %v", failures, err)
+				pi.killReason = ExitReasonHealthCheckFailed
+				pi.Cmd.Process.Signal(syscall.SIGTERM)
+			}
+		}
+		pm.mu.Unlock()
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// HealthResult is the outcome of one health check.
+type HealthResult struct {
+	Time    time.Time
+	Healthy bool
+	Message string
+}
+
+func canRestart(policy string) bool {
+	return policy == "always" || policy == "on-failure"
+}
+
+func runHealthCheck(hc HealthCheck) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hc.Timeout)
+	defer cancel()
+
+	return exec.CommandContext(ctx, "sh", "-c", hc.Command).Run()
+}
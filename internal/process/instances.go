@@ -0,0 +1,129 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+func (spec ProcessSpec) count() int {
+	if spec.Instances <= 0 {
+		return 1
+	}
+	return spec.Instances
+}
+
+func (spec ProcessSpec) instanceName(index int) string {
+	if spec.Instances <= 0 {
+		return spec.Name
+	}
+	return fmt.Sprintf("%s:%d", spec.Name, index)
+}
+
+// environ returns the environment for the next run of pi: the daemon's own
+// environment, the spec's Env and the per-instance variables.
+func (pi *ProcessInformation) environ() []string {
+	env := os.Environ()
+	for k, v := range pi.Spec.Env {
+		env = append(env, k+"="+v)
+	}
+	env = append(env,
+		"GOPM_INSTANCE_ID="+strconv.Itoa(pi.Instance),
+		"GOPM_INSTANCE_COUNT="+strconv.Itoa(pi.Spec.count()),
+	)
+	if pi.Spec.BasePort > 0 {
+		env = append(env, "PORT="+strconv.Itoa(pi.Spec.BasePort+pi.Instance))
+	}
+	return env
+}
+
+// membersLocked returns the processes started from the spec called name,
+// ordered by instance index.
+func (pm *ProcessManager) membersLocked(name string) []*ProcessInformation {
+	var members []*ProcessInformation
+	for _, pi := range pm.processes {
+		if pi.Spec.Name == name && !pi.removing {
+			members = append(members, pi)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Instance < members[j].Instance
+	})
+	return members
+}
+
+// addInstancesLocked registers and launches replicas from..to-1 of spec.
+func (pm *ProcessManager) addInstancesLocked(spec ProcessSpec, from, to int) []*ProcessInformation {
+	var added []*ProcessInformation
+	for i := from; i < to; i++ {
+		pi := &ProcessInformation{
+			Name:     spec.instanceName(i),
+			Status:   "starting",
+			Spec:     spec,
+			Instance: i,
+		}
+		pm.processes[pi.Name] = pi
+		pm.launch(pi)
+		added = append(added, pi)
+	}
+	return added
+}
+
+func (pm *ProcessManager) deleteLocked(pi *ProcessInformation) {
+	// a replica being removed may already have been replaced by a new one
+	// with the same name
+	if pm.processes[pi.Name] != pi {
+		return
+	}
+	delete(pm.processes, pi.Name)
+	delete(pm.logs, pi.Name)
+	removeCgroup(pi.cgroup)
+}
+
+// Scale changes the number of replicas of the process group name. New
+// replicas are started immediately; surplus ones are stopped gracefully,
+// highest index first, and forgotten once they have exited.
+func (pm *ProcessManager) Scale(name string, instances int) error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	return pm.scaleLocked(name, instances)
+}
+
+func (pm *ProcessManager) scaleLocked(name string, instances int) error {
+	if instances < 1 {
+		return fmt.Errorf("instances must be at least 1")
+	}
+	members := pm.membersLocked(name)
+	if len(members) == 0 {
+		return fmt.Errorf("process %q not found", name)
+	}
+	spec := members[0].Spec
+	if spec.Instances <= 0 {
+		return fmt.Errorf("process %q was not started with instances", name)
+	}
+
+	spec.Instances = instances
+	for _, pi := range members {
+		pi.Spec.Instances = instances
+	}
+
+	current := len(members)
+	if instances > current {
+		pm.addInstancesLocked(spec, current, instances)
+		return nil
+	}
+
+	for _, pi := range members[instances:] {
+		pi.removing = true
+		pm.stopLocked(pi, false)
+		go func(pi *ProcessInformation) {
+			pm.waitExited(pi, stopTimeout)
+			pm.mu.Lock()
+			pm.deleteLocked(pi)
+			pm.mu.Unlock()
+		}(pi)
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+)
+
+const (
+	ConcurrencyAllow   = "allow"
+	ConcurrencyForbid  = "forbid"
+	ConcurrencyReplace = "replace"
+
+	defaultJobHistory = 10
+)
+
+type JobSpec struct {
+	Name     string
+	Command  string
+	Args     []string
+	Env      map[string]string
+	Schedule string
+	// ConcurrencyPolicy decides what happens when a run is due while the
+	// previous one is still going: allow, forbid (skip) or replace (kill).
+	ConcurrencyPolicy string
+	// Timeout kills a run that takes longer; zero means no limit.
+	Timeout time.Duration
+	// HistoryLimit is the number of finished runs kept with their logs.
+	HistoryLimit int
+}
+
+type JobRun struct {
+	ID         int
+	Status     string
+	ExitCode   int
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Logs       *LogBuffer
+
+	cancel   context.CancelFunc
+	replaced bool
+}
+
+type Job struct {
+	Spec    JobSpec
+	NextRun time.Time
+	Runs    []*JobRun
+
+	schedule Schedule
+	nextID   int
+	stop     chan struct{}
+}
+
+func (spec JobSpec) validate() error {
+	if spec.Name == "" || spec.Command == "" {
+		return fmt.Errorf("job needs a name and a command")
+	}
+	if _, err := ParseSchedule(spec.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule for job %q: %v", spec.Name, err)
+	}
+	switch spec.ConcurrencyPolicy {
+	case "", ConcurrencyAllow, ConcurrencyForbid, ConcurrencyReplace:
+		return nil
+	}
+	return fmt.Errorf("unknown concurrency policy %q (expected allow|forbid|replace)", spec.ConcurrencyPolicy)
+}
+
+// AddJob registers a scheduled job. With replace set an existing job of the
+// same name is rescheduled with the new spec, keeping its history.
+func (pm *ProcessManager) AddJob(spec JobSpec, replace bool) error {
+	if err := spec.validate(); err != nil {
+		return err
+	}
+	schedule, _ := ParseSchedule(spec.Schedule)
+	if spec.ConcurrencyPolicy == "" {
+		spec.ConcurrencyPolicy = ConcurrencyAllow
+	}
+	if spec.HistoryLimit <= 0 {
+		spec.HistoryLimit = defaultJobHistory
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	job := &Job{
+		Spec:     spec,
+		schedule: schedule,
+		stop:     make(chan struct{}),
+	}
+	if old, ok := pm.jobs[spec.Name]; ok && replace {
+		close(old.stop)
+		job.Runs = old.Runs
+		job.nextID = old.nextID
+	} else if pm.nameTakenLocked(spec.Name) {
+		return fmt.Errorf("process with name %q already exists", spec.Name)
+	}
+
+	pm.jobs[spec.Name] = job
+	go pm.runSchedule(job)
+	return nil
+}
+
+// RemoveJob unschedules a job and kills any of its runs still going.
+func (pm *ProcessManager) RemoveJob(name string) error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	job, ok := pm.jobs[name]
+	if !ok {
+		return fmt.Errorf("job %q not found", name)
+	}
+	close(job.stop)
+	for _, run := range job.Runs {
+		if run.Status == "running" {
+			run.cancel()
+		}
+	}
+	delete(pm.jobs, name)
+	return nil
+}
+
+// ListJobs returns a snapshot of every job, sorted by name.
+func (pm *ProcessManager) ListJobs() []Job {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	jobs := make([]Job, 0, len(pm.jobs))
+	for _, job := range pm.jobs {
+		snapshot := Job{Spec: job.Spec, NextRun: job.NextRun}
+		for _, run := range job.Runs {
+			r := *run
+			snapshot.Runs = append(snapshot.Runs, &r)
+		}
+		jobs = append(jobs, snapshot)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Spec.Name < jobs[j].Spec.Name })
+	return jobs
+}
+
+func (job *Job) logsLocked(run int) (*LogBuffer, error) {
+	if len(job.Runs) == 0 {
+		return nil, fmt.Errorf("job %s has not run yet", job.Spec.Name)
+	}
+	if run == 0 {
+		return job.Runs[len(job.Runs)-1].Logs, nil
+	}
+	for _, r := range job.Runs {
+		if r.ID == run {
+			return r.Logs, nil
+		}
+	}
+	return nil, fmt.Errorf("job %s has no run %d in its history", job.Spec.Name, run)
+}
+
+func (pm *ProcessManager) runSchedule(job *Job) {
+	for {
+		next := job.schedule.Next(time.Now())
+		pm.mu.Lock()
+		job.NextRun = next
+		pm.mu.Unlock()
+		if next.IsZero() {
+			fmt.Printf("job %s has no future activation times\n", job.Spec.Name)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-job.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+			pm.triggerJob(job)
+		}
+	}
+}
+
+func (pm *ProcessManager) triggerJob(job *Job) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	var running []*JobRun
+	for _, run := range job.Runs {
+		if run.Status == "running" {
+			running = append(running, run)
+		}
+	}
+
+	if len(running) > 0 {
+		switch job.Spec.ConcurrencyPolicy {
+		case ConcurrencyForbid:
+			fmt.Printf("job %s skipped: previous run still in progress\n", job.Spec.Name)
+			return
+		case ConcurrencyReplace:
+			for _, run := range running {
+				run.replaced = true
+				run.cancel()
+			}
+		}
+	}
+
+	pm.startRunLocked(job)
+}
+
+func (pm *ProcessManager) startRunLocked(job *Job) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if job.Spec.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), job.Spec.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	job.nextID++
+	run := &JobRun{
+		ID:        job.nextID,
+		Status:    "running",
+		StartedAt: time.Now(),
+		Logs:      NewLogBuffer(logBufferSize),
+		cancel:    cancel,
+	}
+	job.Runs = append(job.Runs, run)
+	job.trimHistoryLocked()
+
+	go pm.executeRun(ctx, fmt.Sprintf("job %s#%d", job.Spec.Name, run.ID), job.Spec, run)
+}
+
+// trimHistoryLocked drops the oldest finished runs beyond the history limit.
+func (job *Job) trimHistoryLocked() {
+	excess := len(job.Runs) - job.Spec.HistoryLimit
+	kept := job.Runs[:0]
+	for _, run := range job.Runs {
+		if excess > 0 && run.Status != "running" {
+			excess--
+			continue
+		}
+		kept = append(kept, run)
+	}
+	job.Runs = kept
+}
+
+// executeRun runs spec to completion and records the outcome in run. label
+// identifies the run in the daemon's output.
+func (pm *ProcessManager) executeRun(ctx context.Context, label string, spec JobSpec, run *JobRun) {
+	fmt.Printf("%s starting: %s %v\n", label, spec.Command, spec.Args)
+
+	cmd := exec.CommandContext(ctx, spec.Command, spec.Args...)
+	cmd.Env = os.Environ()
+	for k, v := range spec.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stdout := &lineWriter{onLine: func(line string) {
+		fmt.Printf("[STDOUT] (%s) %s\n", label, line)
+		run.Logs.Append(line)
+	}}
+	stderr := &lineWriter{onLine: func(line string) {
+		fmt.Printf("[STDERR] (%s) %s\n", label, line)
+		run.Logs.Append(line)
+	}}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	stdout.Flush()
+	stderr.Flush()
+
+	pm.mu.Lock()
+	run.FinishedAt = time.Now()
+	run.ExitCode = -1
+	if cmd.ProcessState != nil {
+		run.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	switch {
+	case run.replaced:
+		run.Status = "replaced"
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		run.Status = "timed-out"
+	case err != nil:
+		run.Status = "failed"
+	default:
+		run.Status = "succeeded"
+	}
+	status, exitCode := run.Status, run.ExitCode
+	pm.mu.Unlock()
+
+	if err != nil && cmd.ProcessState == nil {
+		run.Logs.Append(fmt.Sprintf("failed to start: %v", err))
+	}
+	run.Logs.Close()
+	run.cancel()
+	fmt.Printf("%s finished: %s (exit code %d)\n", label, status, exitCode)
+}
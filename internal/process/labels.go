@@ -0,0 +1,112 @@
+package process
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// requirement is one term of a label selector.
+type requirement struct {
+	key   string
+	op    string // "=", "!=", "exists" or "!exists"
+	value string
+}
+
+// Selector matches processes by their labels. Every requirement has to
+// hold.
+type Selector []requirement
+
+// ParseSelector parses a comma separated list of requirements:
+// "key=value" (or "=="), "key!=value", "key" for presence and "!key" for
+// absence. An empty string selects everything.
+func ParseSelector(s string) (Selector, error) {
+	var sel Selector
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		var r requirement
+		if key, value, ok := strings.Cut(term, "!="); ok {
+			r = requirement{key: key, op: "!=", value: value}
+		} else if key, value, ok := strings.Cut(term, "="); ok {
+			r = requirement{key: key, op: "=", value: strings.TrimPrefix(value, "=")}
+		} else if key, ok := strings.CutPrefix(term, "!"); ok {
+			r = requirement{key: key, op: "!exists"}
+		} else {
+			r = requirement{key: term, op: "exists"}
+		}
+		r.key = strings.TrimSpace(r.key)
+		r.value = strings.TrimSpace(r.value)
+		if r.key == "" {
+			return nil, fmt.Errorf("invalid selector term %q", term)
+		}
+		sel = append(sel, r)
+	}
+	return sel, nil
+}
+
+func (sel Selector) Matches(labels map[string]string) bool {
+	for _, r := range sel {
+		value, ok := labels[r.key]
+		switch r.op {
+		case "=":
+			if !ok || value != r.value {
+				return false
+			}
+		case "!=":
+			if ok && value == r.value {
+				return false
+			}
+		case "exists":
+			if !ok {
+				return false
+			}
+		case "!exists":
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// IsPattern tells whether name selects processes by glob or is "all",
+// rather than naming a single process or group.
+func IsPattern(name string) bool {
+	return name == "all" || strings.ContainsAny(name, "*?[")
+}
+
+// Select returns the processes whose name or group name matches pattern
+// and whose labels match sel, sorted by name. An empty pattern or "all"
+// matches every process.
+func (pm *ProcessManager) Select(pattern string, sel Selector) ([]*ProcessInformation, error) {
+	if pattern == "" {
+		pattern = "all"
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	var selected []*ProcessInformation
+	for _, pi := range pm.processes {
+		if pi.removing || !sel.Matches(pi.Spec.Labels) {
+			continue
+		}
+		if pattern != "all" {
+			byName, _ := path.Match(pattern, pi.Name)
+			byGroup, _ := path.Match(pattern, pi.Spec.Name)
+			if !byName && !byGroup {
+				continue
+			}
+		}
+		selected = append(selected, pi)
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].Name < selected[j].Name })
+	return selected, nil
+}
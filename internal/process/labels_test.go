@@ -0,0 +1,157 @@
+package process
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Selector
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "tier=web", want: Selector{{key: "tier", op: "=", value: "web"}}},
+		{in: "tier==web", want: Selector{{key: "tier", op: "=", value: "web"}}},
+		{in: "tier!=web", want: Selector{{key: "tier", op: "!=", value: "web"}}},
+		{in: "canary", want: Selector{{key: "canary", op: "exists"}}},
+		{in: "!canary", want: Selector{{key: "canary", op: "!exists"}}},
+		{in: "tier=", want: Selector{{key: "tier", op: "=", value: ""}}},
+		{
+			in: " tier = web , !canary,,env!=prod ",
+			want: Selector{
+				{key: "tier", op: "=", value: "web"},
+				{key: "canary", op: "!exists"},
+				{key: "env", op: "!=", value: "prod"},
+			},
+		},
+		{in: "=web", wantErr: true},
+		{in: "!=web", wantErr: true},
+		{in: "!", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSelector(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseSelector(%q) = %v, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSelector(%q) error = %v", tt.in, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSelector(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	labels := map[string]string{"tier": "web", "env": "prod", "empty": ""}
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"", true},
+		{"tier=web", true},
+		{"tier=db", false},
+		{"missing=web", false},
+		{"tier!=db", true},
+		{"tier!=web", false},
+		{"missing!=web", true},
+		{"tier", true},
+		{"empty", true},
+		{"empty=", true},
+		{"missing", false},
+		{"!missing", true},
+		{"!tier", false},
+		{"tier=web,env=prod", true},
+		{"tier=web,env=dev", false},
+		{"tier=web,!canary", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			sel, err := ParseSelector(tt.selector)
+			if err != nil {
+				t.Fatalf("ParseSelector(%q) error = %v", tt.selector, err)
+			}
+			if got := sel.Matches(labels); got != tt.want {
+				t.Errorf("%q matches %v = %v, want %v", tt.selector, labels, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	pm := &ProcessManager{processes: map[string]*ProcessInformation{}}
+	add := func(name, group string, labels map[string]string) {
+		pm.processes[name] = &ProcessInformation{Name: name, Spec: ProcessSpec{Name: group, Labels: labels}}
+	}
+	add("web-0", "web", map[string]string{"tier": "web"})
+	add("web-1", "web", map[string]string{"tier": "web", "canary": "true"})
+	add("db", "db", map[string]string{"tier": "db"})
+	add("worker", "worker", nil)
+
+	tests := []struct {
+		pattern  string
+		selector string
+		want     []string
+		wantErr  bool
+	}{
+		{pattern: "", want: []string{"db", "web-0", "web-1", "worker"}},
+		{pattern: "all", selector: "tier=web", want: []string{"web-0", "web-1"}},
+		{pattern: "web", want: []string{"web-0", "web-1"}},
+		{pattern: "w*", selector: "!canary", want: []string{"web-0", "worker"}},
+		{pattern: "web-?", selector: "canary", want: []string{"web-1"}},
+		{pattern: "all", selector: "tier", want: []string{"db", "web-0", "web-1"}},
+		{pattern: "nothing", want: nil},
+		{pattern: "[", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.selector, func(t *testing.T) {
+			sel, err := ParseSelector(tt.selector)
+			if err != nil {
+				t.Fatalf("ParseSelector(%q) error = %v", tt.selector, err)
+			}
+			selected, err := pm.Select(tt.pattern, sel)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Select(%q) succeeded, want an error", tt.pattern)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Select(%q) error = %v", tt.pattern, err)
+			}
+			var got []string
+			for _, pi := range selected {
+				got = append(got, pi.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select(%q, %q) = %v, want %v", tt.pattern, tt.selector, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPattern(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"all", true},
+		{"web-*", true},
+		{"web-?", true},
+		{"web-[01]", true},
+		{"web", false},
+		{"web-0", false},
+	}
+	for _, tt := range tests {
+		if got := IsPattern(tt.name); got != tt.want {
+			t.Errorf("IsPattern(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
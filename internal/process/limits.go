@@ -0,0 +1,250 @@
+package process
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// ResourceLimits are applied every time a process is spawned. Rlimits are
+// set by the child on itself before it execs the command (see
+// spawnThrough); the rest needs a delegated cgroup v2 subtree.
+type ResourceLimits struct {
+	// rlimits, nil leaves the daemon's own limit in place
+	NoFile       *uint64
+	Core         *uint64
+	AddressSpace *uint64
+
+	// cgroup v2 settings, zero means unlimited
+	MemoryMax uint64
+	CPUs      float64
+	PidsMax   int64
+	IOWeight  int
+}
+
+func (l *ResourceLimits) needsCgroup() bool {
+	return l != nil && (l.MemoryMax > 0 || l.CPUs > 0 || l.PidsMax > 0 || l.IOWeight > 0)
+}
+
+func (l *ResourceLimits) hasRlimits() bool {
+	return l != nil && (l.NoFile != nil || l.Core != nil || l.AddressSpace != nil)
+}
+
+// applyRlimits sets the rlimits of the calling process.
+func applyRlimits(l *ResourceLimits) error {
+	if l == nil {
+		return nil
+	}
+	set := func(resource int, value *uint64, name string) error {
+		if value == nil {
+			return nil
+		}
+		limit := unix.Rlimit{Cur: *value, Max: *value}
+		if err := unix.Prlimit(0, resource, &limit, nil); err != nil {
+			return fmt.Errorf("set %s limit: %v", name, err)
+		}
+		return nil
+	}
+	if err := set(unix.RLIMIT_NOFILE, l.NoFile, "open files"); err != nil {
+		return err
+	}
+	if err := set(unix.RLIMIT_CORE, l.Core, "core size"); err != nil {
+		return err
+	}
+	return set(unix.RLIMIT_AS, l.AddressSpace, "address space")
+}
+
+const cgroupMount = "/sys/fs/cgroup"
+
+// cgroupControllers are enabled for the processes' cgroups when available.
+var cgroupControllers = []string{"memory", "cpu", "pids", "io"}
+
+// cgroupRoot finds the cgroup subtree the daemon may manage and prepares it
+// for child cgroups. It uses GOPM_CGROUP_ROOT if set, and otherwise the
+// daemon's own cgroup, into which it moves itself one level down so that
+// controllers can be enabled for its children.
+func (pm *ProcessManager) cgroupRoot() (string, error) {
+	pm.cgroupOnce.Do(func() {
+		pm.cgroupDir, pm.cgroupErr = setupCgroupRoot()
+		if pm.cgroupErr != nil {
+			fmt.Printf("cgroup limits unavailable: %v\n", pm.cgroupErr)
+		}
+	})
+	return pm.cgroupDir, pm.cgroupErr
+}
+
+func setupCgroupRoot() (string, error) {
+	root := os.Getenv("GOPM_CGROUP_ROOT")
+	if root == "" {
+		own, err := ownCgroup()
+		if err != nil {
+			return "", err
+		}
+		root = filepath.Join(cgroupMount, own)
+	}
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("%s is not a cgroup v2 directory", root)
+	}
+	if err := unix.Access(root, unix.W_OK); err != nil {
+		return "", fmt.Errorf("cgroup %s is not delegated to the daemon: %v", root, err)
+	}
+
+	// a cgroup with processes in it can't hand controllers to children
+	procs, err := os.ReadFile(filepath.Join(root, "cgroup.procs"))
+	if err != nil {
+		return "", err
+	}
+	if len(bytes.TrimSpace(procs)) > 0 {
+		leaf := filepath.Join(root, "gopm-daemon")
+		if err := os.MkdirAll(leaf, 0o755); err != nil {
+			return "", err
+		}
+		// other processes left behind make enabling controllers fail below
+		pid := []byte(strconv.Itoa(os.Getpid()))
+		if err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), pid, 0o644); err != nil {
+			return "", fmt.Errorf("move daemon into %s: %v", leaf, err)
+		}
+	}
+
+	available, err := os.ReadFile(filepath.Join(root, "cgroup.controllers"))
+	if err != nil {
+		return "", err
+	}
+	enabled := strings.Fields(string(available))
+	for _, controller := range cgroupControllers {
+		if !slices.Contains(enabled, controller) {
+			continue
+		}
+		err := os.WriteFile(filepath.Join(root, "cgroup.subtree_control"), []byte("+"+controller), 0o644)
+		if err != nil {
+			return "", fmt.Errorf("enable %s controller in %s: %v", controller, root, err)
+		}
+	}
+	return root, nil
+}
+
+func ownCgroup() (string, error) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("daemon is not in a cgroup v2 hierarchy")
+}
+
+// prepareCgroup creates the cgroup of a process and writes its limits.
+func (pm *ProcessManager) prepareCgroup(name string, l *ResourceLimits) (string, error) {
+	root, err := pm.cgroupRoot()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, cgroupName(name))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	settings := make(map[string]string)
+	if l.MemoryMax > 0 {
+		settings["memory.max"] = strconv.FormatUint(l.MemoryMax, 10)
+	}
+	if l.CPUs > 0 {
+		settings["cpu.max"] = fmt.Sprintf("%d 100000", int64(l.CPUs*100000))
+	}
+	if l.PidsMax > 0 {
+		settings["pids.max"] = strconv.FormatInt(l.PidsMax, 10)
+	}
+	if l.IOWeight > 0 {
+		settings["io.weight"] = fmt.Sprintf("default %d", l.IOWeight)
+	}
+	// reset what isn't set, in case the limits changed since the last run
+	for file, value := range cgroupDefaults {
+		if _, ok := settings[file]; ok {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+			// controller not available and nothing to limit
+			continue
+		}
+		settings[file] = value
+	}
+	for file, value := range settings {
+		path := filepath.Join(dir, file)
+		if err := os.WriteFile(path, []byte(value), 0o644); err != nil {
+			return "", fmt.Errorf("write %s: %v", file, err)
+		}
+	}
+	return dir, nil
+}
+
+// cgroupDefaults are the values of the cgroup settings without a limit.
+var cgroupDefaults = map[string]string{
+	"memory.max": "max",
+	"cpu.max":    "max 100000",
+	"pids.max":   "max",
+	"io.weight":  "default 100",
+}
+
+func cgroupName(process string) string {
+	return "proc-" + strings.ReplaceAll(process, "/", "_")
+}
+
+// CgroupUsage is read from a process's cgroup.
+type CgroupUsage struct {
+	Path          string
+	MemoryCurrent uint64
+	CPUUsageUsec  uint64
+	PidsCurrent   uint64
+}
+
+// Usage returns the current cgroup usage of pi, if it runs in one.
+func (pm *ProcessManager) Usage(pi *ProcessInformation) (*CgroupUsage, error) {
+	pm.mu.Lock()
+	dir := pi.cgroup
+	pm.mu.Unlock()
+	if dir == "" {
+		return nil, nil
+	}
+
+	usage := &CgroupUsage{Path: dir}
+	usage.MemoryCurrent, _ = readUint(filepath.Join(dir, "memory.current"))
+	usage.PidsCurrent, _ = readUint(filepath.Join(dir, "pids.current"))
+	stat, err := os.ReadFile(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(stat), "\n") {
+		if value, ok := strings.CutPrefix(line, "usage_usec "); ok {
+			usage.CPUUsageUsec, _ = strconv.ParseUint(value, 10, 64)
+		}
+	}
+	return usage, nil
+}
+
+func readUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// removeCgroup deletes the cgroup of a process that is gone for good.
+func removeCgroup(dir string) {
+	if dir != "" {
+		os.Remove(dir)
+	}
+}
@@ -0,0 +1,117 @@
+package process
+
+import (
+	"bytes"
+	"sync"
+)
+
+const logBufferSize = 1000
+
+// LogBuffer keeps the most recent lines of output and fans new lines out to
+// followers.
+type LogBuffer struct {
+	mu     sync.Mutex
+	lines  []string
+	max    int
+	subs   map[chan string]struct{}
+	closed bool
+}
+
+func NewLogBuffer(max int) *LogBuffer {
+	return &LogBuffer{
+		max:  max,
+		subs: make(map[chan string]struct{}),
+	}
+}
+
+func (b *LogBuffer) Append(line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.lines) >= b.max {
+		b.lines = b.lines[1:]
+	}
+	b.lines = append(b.lines, line)
+	for ch := range b.subs {
+		select {
+		case ch <- line:
+		default:
+			// slow follower, drop the line rather than block the process
+		}
+	}
+}
+
+// Lines returns a copy of the buffered lines.
+func (b *LogBuffer) Lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]string(nil), b.lines...)
+}
+
+// Subscribe returns the buffered lines and a channel receiving every line
+// appended afterwards. The channel is closed when the buffer is closed or
+// cancel is called.
+func (b *LogBuffer) Subscribe() ([]string, <-chan string, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan string, 100)
+	lines := append([]string(nil), b.lines...)
+	if b.closed {
+		close(ch)
+		return lines, ch, func() {}
+	}
+
+	b.subs[ch] = struct{}{}
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	return lines, ch, cancel
+}
+
+// Close ends all subscriptions; the buffered lines remain readable.
+func (b *LogBuffer) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// lineWriter calls onLine for every complete line written to it. It is
+// used as cmd.Stdout/cmd.Stderr so that exec.Cmd.Wait only returns once
+// all output has been consumed.
+type lineWriter struct {
+	onLine func(string)
+	buf    []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.onLine(string(bytes.TrimSuffix(w.buf[:i], []byte("\r"))))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush emits a trailing line that wasn't terminated by a newline.
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.onLine(string(w.buf))
+		w.buf = nil
+	}
+}
@@ -1,177 +1,659 @@
 package process
 
 import (
-	"bufio"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"sort"
 	"sync"
 	"syscall"
 	"time"
+
+	"golang.org/x/sys/unix"
 )
 
+type ProcessSpec struct {
+	Name        string
+	Command     string
+	Args        []string
+	Env         map[string]string
+	Cwd         string
+	AutoRestart string
+	DependsOn   []Dependency
+	HealthCheck *HealthCheck
+	// Instances > 0 runs that many replicas named "<name>:<index>".
+	Instances int
+	// BasePort, if set, is exported to each replica as PORT=BasePort+index.
+	BasePort int
+	// Watch restarts the process when files under the given paths change.
+	Watch      *WatchSpec
+	Limits     *ResourceLimits
+	Scheduling *Scheduling
+	// TTY runs the process on a pseudo-terminal that clients can attach to.
+	TTY bool
+	// Stdin keeps a pipe to the process's stdin open for WriteStdin.
+	Stdin bool
+	// Labels are free-form key=value pairs that selectors match against.
+	Labels map[string]string
+	// MaxMemory (bytes) and MaxCPU (percent of one core) restart the
+	// process gracefully once exceeded for ThresholdDuration.
+	MaxMemory         uint64
+	MaxCPU            float64
+	ThresholdDuration time.Duration
+}
+
 type ProcessInformation struct {
-	Cmd    *exec.Cmd
-	Name   string
-	PID    int
-	Status string
+	Cmd     *exec.Cmd
+	Name    string
+	PID     int
+	Status  string
+	Healthy bool
+	Spec    ProcessSpec
+	// Instance is the replica index within Spec.Name.
+	Instance  int
+	StartedAt time.Time
+	// Stats is the latest resource sample, taken while running.
+	Stats Stats
+	// Restarts counts restarts by the daemon; RestartReason is the last one.
+	Restarts      int
+	RestartReason string
+	// Exit describes how the last run ended.
+	Exit *ExitStatus
+
+	stopRequested bool
+	removing      bool
+	// supervised is set while a supervisor goroutine runs for the process,
+	// including while it waits to restart it; exited is closed when it
+	// returns.
+	supervised bool
+	exited     chan struct{}
+	cgroup     string
+	sampling   sampleState
+	// killReason is set when the daemon stops or kills the process on its own.
+	killReason string
+	term       *terminal
+	stdin      io.WriteCloser
+	// exits and health are the most recent exits and health check
+	// results, oldest first.
+	exits  []ExitStatus
+	health []HealthResult
 }
 
 type ProcessManager struct {
-	mu          sync.Mutex
-	processes   map[string]*ProcessInformation
-	logChannels map[string]chan string
+	mu        sync.Mutex
+	processes map[string]*ProcessInformation
+	logs      map[string]*LogBuffer
+	jobs      map[string]*Job
+	tasks     map[string]*Task
+	watchers  map[string]*watcher
+	events    *EventLog
+
+	cgroupOnce sync.Once
+	cgroupDir  string
+	cgroupErr  error
 }
 
 func NewProcessManager() *ProcessManager {
-	return &ProcessManager{
-		processes:   make(map[string]*ProcessInformation),
-		logChannels: make(map[string]chan string),
+	pm := &ProcessManager{
+		processes: make(map[string]*ProcessInformation),
+		logs:      make(map[string]*LogBuffer),
+		jobs:      make(map[string]*Job),
+		tasks:     make(map[string]*Task),
+		watchers:  make(map[string]*watcher),
+		events:    NewEventLog(),
 	}
+	go pm.runSampler()
+	return pm
 }
 
-func (pm *ProcessManager) StartProcess(name string, autoRestart string, command string, args ...string) (*ProcessInformation, error) {
+func (pm *ProcessManager) StartProcess(spec ProcessSpec) (*ProcessInformation, error) {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
-	if _, exists := pm.processes[name]; exists {
-		return nil, fmt.Errorf("process with name %q already exists", name)
+	if pm.nameTakenLocked(spec.Name) {
+		return nil, fmt.Errorf("process with name %q already exists", spec.Name)
+	}
+	for _, dep := range spec.DependsOn {
+		if err := dep.validate(); err != nil {
+			return nil, err
+		}
+		if len(pm.membersLocked(dep.Name)) == 0 {
+			return nil, fmt.Errorf("process %q depends on unknown process %q", spec.Name, dep.Name)
+		}
 	}
 
-	fmt.Printf("executing command: %s %v\n", command, args)
+	added := pm.addInstancesLocked(spec, 0, spec.count())
+	if err := pm.watchLocked(spec); err != nil {
+		fmt.Printf("process %s: file watch disabled: %v\n", spec.Name, err)
+		pm.events.Record(spec.Name, "watch", "file watch disabled: %v", err)
+	}
+	return added[0], nil
+}
+
+// launch waits for the dependencies of pi and then supervises it according
+// to its restart policy. It does nothing while pi is still supervised, as
+// two supervisors would run it twice. pm.mu must be held.
+func (pm *ProcessManager) launch(pi *ProcessInformation) {
+	name := pi.Name
+	if pi.supervised {
+		fmt.Printf("process %s is already supervised, not launching it again\n", name)
+		return
+	}
+	fmt.Printf("executing command: %s %v\n", pi.Spec.Command, pi.Spec.Args)
 
-	if _, ok := pm.logChannels[name]; !ok {
-		pm.logChannels[name] = make(chan string, 100) // buffer size can be adjusted
+	if _, ok := pm.logs[name]; !ok {
+		pm.logs[name] = NewLogBuffer(logBufferSize)
 	}
 
-	pi := &ProcessInformation{
-		Name:   name,
-		Status: "starting",
+	pi.stopRequested = false
+	pi.Healthy = false
+	pi.supervised = true
+	exited := make(chan struct{})
+	pi.exited = exited
+	if len(pi.Spec.DependsOn) > 0 {
+		pi.Status = "waiting"
+	} else {
+		pi.Status = "starting"
 	}
 
-	pm.processes[name] = pi
-	runOnce := func() error {
-		cmd := exec.Command(command, args...)
+	go func() {
+		if err := pm.waitForDependencies(pi); err != nil {
+			fmt.Printf("process %s not started: %v\n", name, err)
+			pm.events.Record(name, "failed", "not started: %v", err)
+			pm.mu.Lock()
+			if !pi.stopRequested {
+				pi.Status = "failed"
+			}
+			pi.supervised = false
+			close(exited)
+			pm.mu.Unlock()
+			return
+		}
+		pm.supervise(pi, exited)
+	}()
+}
 
+// supervise runs pi until it shouldn't be restarted anymore, then closes
+// exited.
+func (pm *ProcessManager) supervise(pi *ProcessInformation, exited chan struct{}) {
+	defer func() {
 		pm.mu.Lock()
-		pi.Cmd = cmd
+		pi.supervised = false
+		close(exited)
 		pm.mu.Unlock()
+	}()
 
-		stdout, err := cmd.StdoutPipe()
+	policy := pi.Spec.AutoRestart
+	switch policy {
+	case "always", "on-failure", "never", "":
+	default:
+		fmt.Printf("unrecognized auto-restart policy: %q (defaulting to never)\n", policy)
+		policy = "never"
+	}
+
+	for {
+		waitErr := pm.runOnce(pi)
+
+		pm.mu.Lock()
+		stopped := pi.stopRequested
+		pm.mu.Unlock()
+		if stopped {
+			return
+		}
+
+		switch policy {
+		case "always":
+		case "on-failure":
+			if waitErr == nil {
+				return
+			}
+		default:
+			return
+		}
+		pm.mu.Lock()
+		pi.Restarts++
+		pi.RestartReason = "auto-restart after exit"
+		pm.mu.Unlock()
+		time.Sleep(1 * time.Second) // optional delay
+
+		// stopped or removed while waiting to restart
+		pm.mu.Lock()
+		stopped = pi.stopRequested
+		pm.mu.Unlock()
+		if stopped {
+			return
+		}
+	}
+}
+
+func (pm *ProcessManager) runOnce(pi *ProcessInformation) error {
+	name := pi.Name
+	cmd := exec.Command(pi.Spec.Command, pi.Spec.Args...)
+	cmd.Env = pi.environ()
+	cmd.Dir = pi.Spec.Cwd
+	spawnThrough(cmd, spawnSettings{Limits: pi.Spec.Limits, Scheduling: pi.Spec.Scheduling})
+
+	pm.mu.Lock()
+	pi.Cmd = cmd
+	logs := pm.logs[name]
+	pm.mu.Unlock()
+
+	stdout := &lineWriter{onLine: func(line string) {
+		fmt.Printf("[STDOUT] (%s) %s\n", name, line)
+		logs.Append(line)
+	}}
+	stderr := &lineWriter{onLine: func(line string) {
+		fmt.Printf("[STDERR] (%s) %s\n", name, line)
+		logs.Append(line)
+	}}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// don't let children that inherited the output pipes keep Wait blocked
+	cmd.WaitDelay = time.Second
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+
+	var term *terminal
+	var tty *os.File
+	if pi.Spec.TTY {
+		var err error
+		term, tty, err = openTerminal()
 		if err != nil {
+			pm.mu.Lock()
+			pi.Status = "failed"
+			pm.mu.Unlock()
+			pm.events.Record(name, "failed", "%v", err)
 			return err
 		}
-		stderr, err := cmd.StderrPipe()
-		if err != nil {
+		// the terminal becomes the controlling terminal of a new session
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+		cmd.SysProcAttr.Setsid = true
+		cmd.SysProcAttr.Setctty = true
+	} else {
+		// its own process group, so it can be signalled as a whole
+		cmd.SysProcAttr.Setpgid = true
+	}
+	var stdin io.WriteCloser
+	if pi.Spec.Stdin && !pi.Spec.TTY {
+		var err error
+		if stdin, err = cmd.StdinPipe(); err != nil {
 			return err
 		}
+	}
 
-		if err := cmd.Start(); err != nil {
-			return err
+	cgroupFD := -1
+	cgroupDir := ""
+	if pi.Spec.Limits.needsCgroup() {
+		dir, err := pm.prepareCgroup(name, pi.Spec.Limits)
+		if err == nil {
+			cgroupFD, err = unix.Open(dir, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+		}
+		if err != nil {
+			fmt.Printf("process %s: running without cgroup limits: %v\n", name, err)
+			pm.events.Record(name, "limits", "running without cgroup limits: %v", err)
+		} else {
+			// clone straight into the cgroup so no child escapes the limits
+			cmd.SysProcAttr.UseCgroupFD = true
+			cmd.SysProcAttr.CgroupFD = cgroupFD
+			cgroupDir = dir
+			pm.mu.Lock()
+			pi.cgroup = dir
+			pm.mu.Unlock()
 		}
+	}
 
+	oomBefore := oomKills(cgroupDir)
+	err := cmd.Start()
+	if cgroupFD >= 0 {
+		unix.Close(cgroupFD)
+	}
+	if tty != nil {
+		// only the child may hold the slave side, or reads from the master
+		// never see the end of the output
+		tty.Close()
+	}
+	if err != nil {
+		if term != nil {
+			term.close()
+		}
 		pm.mu.Lock()
-		pi.PID = cmd.Process.Pid
-		pi.Status = "running"
+		pi.Status = "failed"
 		pm.mu.Unlock()
+		fmt.Printf("process %s failed to start: %v\n", name, err)
+		pm.events.Record(name, "failed", "failed to start: %v", err)
+		return err
+	}
 
+	pm.mu.Lock()
+	pi.PID = cmd.Process.Pid
+	pi.Status = "running"
+	pi.StartedAt = time.Now()
+	pi.Healthy = pi.Spec.HealthCheck == nil
+	pi.Stats = Stats{}
+	pi.sampling = sampleState{}
+	pi.killReason = ""
+	pi.term = term
+	pi.stdin = stdin
+	if pi.stopRequested {
+		// stopped while it was being started
+		cmd.Process.Signal(syscall.SIGTERM)
+	}
+	pm.mu.Unlock()
+	pm.events.Record(name, "started", "pid %d", cmd.Process.Pid)
+
+	var pumped chan struct{}
+	if term != nil {
+		pumped = make(chan struct{})
 		go func() {
-			scanner := bufio.NewScanner(stdout)
-			for scanner.Scan() {
-				line := scanner.Text()
-				fmt.Printf("[STDOUT] (%s) %s\n", name, line)
-
-				pm.mu.Lock()
-				pm.logChannels[name] <- line
-				pm.mu.Unlock()
-			}
-			if err := scanner.Err(); err != nil {
-				fmt.Printf("error reading stdout: %v\n", err)
-			}
-		}()
-		go func() {
-			scanner := bufio.NewScanner(stderr)
-			for scanner.Scan() {
-				fmt.Printf("[STDERR] (%s) %s\n", name, scanner.Text())
-			}
-			if err := scanner.Err(); err != nil {
-				fmt.Printf("error reading stderr: %v\n", err)
-			}
+			defer close(pumped)
+			term.pump(func(data []byte) { stdout.Write(data) })
 		}()
+	}
 
-		waitErr := cmd.Wait()
+	done := make(chan struct{})
+	if pi.Spec.HealthCheck != nil {
+		go pm.checkHealth(pi, *pi.Spec.HealthCheck, done)
+	}
 
+	waitErr := cmd.Wait()
+	close(done)
+	pm.mu.Lock()
+	// Wait has closed the pipe
+	pi.stdin = nil
+	pm.mu.Unlock()
+	if term != nil {
+		// same grace period as for output pipes held by leftover children
+		select {
+		case <-pumped:
+		case <-time.After(cmd.WaitDelay):
+		}
+		term.close()
 		pm.mu.Lock()
-		pi.Status = "exited"
+		pi.term = nil
 		pm.mu.Unlock()
+	}
+	stdout.Flush()
+	stderr.Flush()
+	oomKilled := oomKills(cgroupDir) > oomBefore
 
-		if waitErr != nil {
-			fmt.Printf("process %s exited with error: %v\n", name, waitErr)
-		} else {
-			fmt.Printf("process %s exited successfully\n", name)
-		}
-		return waitErr
+	pm.mu.Lock()
+	if pi.stopRequested {
+		pi.Status = "stopped"
+	} else {
+		pi.Status = "exited"
 	}
+	pi.Healthy = false
+	exit := exitStatus(cmd.ProcessState, pi.stopRequested, pi.killReason, oomKilled)
+	pi.Exit = &exit
+	pi.exits = appendHistory(pi.exits, exit)
+	pm.mu.Unlock()
+
+	pm.events.Record(name, exit.Reason, "%s", exit.detail())
+	if waitErr != nil {
+		fmt.Printf("process %s exited with error: %v (%s)\n", name, waitErr, exit.Reason)
+	} else {
+		fmt.Printf("process %s exited successfully\n", name)
+	}
+	return waitErr
+}
 
-	switch autoRestart {
-	case "always":
-		go func() {
-			for {
-				_ = runOnce()
-				time.Sleep(1 * time.Second) // optional delay
-			}
-		}()
+func (pm *ProcessManager) StopProcess(pi *ProcessInformation, force bool) error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
 
-	case "on-failure":
-		go func() {
-			for {
-				waitErr := runOnce()
-				if waitErr == nil {
-					break
-				}
-				time.Sleep(1 * time.Second) // optional delay
-			}
-		}()
+	return pm.stopLocked(pi, force)
+}
 
-	case "never", "":
-		go func() {
-			_ = runOnce()
-		}()
+func (pm *ProcessManager) stopLocked(pi *ProcessInformation, force bool) error {
+	if pi.Status == "waiting" {
+		// still blocked on its dependencies; waitForDependencies gives up
+		// once it sees the stop request
+		pi.stopRequested = true
+		pi.Status = "stopped"
+		pm.events.Record(pi.Name, "stop", "stopped while waiting for dependencies")
+		return nil
+	}
+	if pi.Status != "running" && pi.supervised {
+		// between restarts, or about to start: the supervisor gives up
+		// once it sees the stop request, and runOnce stops a run it has
+		// just started
+		pi.stopRequested = true
+		if !pi.isActive() {
+			pi.Status = "stopped"
+		}
+		pm.events.Record(pi.Name, "stop", "stopped while waiting to restart")
+		return nil
+	}
+	if pi.Cmd == nil || pi.Cmd.Process == nil || pi.Status != "running" {
+		return fmt.Errorf("process not running")
+	}
 
-	default:
-		fmt.Printf("unrecognized auto-restart policy: %q (defaulting to never)\n", autoRestart)
-		go func() {
-			_ = runOnce()
-		}()
+	pi.stopRequested = true
+	pm.events.Record(pi.Name, "stop", "stop requested (force=%t)", force)
+	if force {
+		return pi.Cmd.Process.Kill()
+	}
+	return pi.Cmd.Process.Signal(syscall.SIGTERM)
+}
+
+// waitExited blocks until the supervisor of pi has returned or timeout has
+// passed, escalating to a kill on timeout.
+func (pm *ProcessManager) waitExited(pi *ProcessInformation, timeout time.Duration) {
+	pm.mu.Lock()
+	exited := pi.exited
+	pm.mu.Unlock()
+	if exited == nil {
+		return
 	}
 
-	return pi, nil
+	select {
+	case <-exited:
+	case <-time.After(timeout):
+		fmt.Printf("process %s did not exit within %s, killing it\n", pi.Name, timeout)
+		pm.mu.Lock()
+		if pi.Cmd != nil && pi.Cmd.Process != nil {
+			pi.Cmd.Process.Kill()
+		}
+		pm.mu.Unlock()
+		<-exited
+	}
 }
 
-func (pm *ProcessManager) StopProcess(pi *ProcessInformation, force bool) error {
-	fmt.Printf("DEBUG: pm=%p, pi=%+v\n", pm, pi)
-	if pi.Cmd.Process == nil {
-		return fmt.Errorf("process not running")
+// StartAll starts every known process in namespace (or in every namespace
+// if it is empty) that isn't running, dependencies first. Processes waiting
+// to be restarted by their supervisor are left to it.
+func (pm *ProcessManager) StartAll(namespace string) error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	order, err := startOrder(pm.specsLocked())
+	if err != nil {
+		return err
 	}
-	fmt.Println("bongo")
-	if force {
-		err := pi.Cmd.Process.Kill()
-		if err != nil {
-			return err
+	for _, name := range order {
+		if namespace != "" && Namespace(name) != namespace {
+			continue
+		}
+		for _, pi := range pm.membersLocked(name) {
+			if !pi.isActive() && !pi.supervised {
+				pm.launch(pi)
+			}
 		}
+	}
+	return nil
+}
 
-		pi.Status = "stopped"
-		return nil
+// StopAll stops every running process in namespace, or in every namespace
+// if it is empty, in reverse dependency order, waiting for each to exit
+// before stopping the processes it depends on. Processes waiting to be
+// restarted are stopped too.
+func (pm *ProcessManager) StopAll(namespace string, force bool) error {
+	pm.mu.Lock()
+	order, err := startOrder(pm.specsLocked())
+	pm.mu.Unlock()
+	if err != nil {
+		return err
 	}
 
-	err := pi.Cmd.Process.Signal(syscall.SIGTERM)
+	for i := len(order) - 1; i >= 0; i-- {
+		if namespace != "" && Namespace(order[i]) != namespace {
+			continue
+		}
+		pm.mu.Lock()
+		members := pm.membersLocked(order[i])
+		var stopping []*ProcessInformation
+		for _, pi := range members {
+			if err := pm.stopLocked(pi, force); err == nil {
+				stopping = append(stopping, pi)
+			}
+		}
+		pm.mu.Unlock()
+
+		for _, pi := range stopping {
+			pm.waitExited(pi, stopTimeout)
+		}
+	}
+	return nil
+}
+
+// Apply registers the given specs and starts them in dependency order.
+// Processes that already exist keep running; stopped ones are started again
+// with the new spec.
+func (pm *ProcessManager) Apply(specs []ProcessSpec) error {
+	pm.stopReplaced(specs)
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	merged := pm.specsLocked()
+	index := make(map[string]int, len(merged))
+	for i, spec := range merged {
+		index[spec.Name] = i
+	}
+	for _, spec := range specs {
+		if i, ok := index[spec.Name]; ok {
+			merged[i] = spec
+		} else {
+			index[spec.Name] = len(merged)
+			merged = append(merged, spec)
+		}
+	}
+
+	order, err := startOrder(merged)
 	if err != nil {
 		return err
 	}
 
-	pi.Status = "stopped"
+	applied := make(map[string]ProcessSpec, len(specs))
+	for _, spec := range specs {
+		applied[spec.Name] = spec
+	}
+	for _, name := range order {
+		spec, ok := applied[name]
+		if !ok {
+			continue
+		}
+		members := pm.membersLocked(name)
+		active := false
+		for _, pi := range members {
+			active = active || pi.isActive()
+		}
+		if !active {
+			for _, pi := range members {
+				pm.deleteLocked(pi)
+			}
+			pm.addInstancesLocked(spec, 0, spec.count())
+			if err := pm.watchLocked(spec); err != nil {
+				fmt.Printf("process %s: file watch disabled: %v\n", spec.Name, err)
+				pm.events.Record(spec.Name, "watch", "file watch disabled: %v", err)
+			}
+			continue
+		}
+		if spec.Instances > 0 && spec.Instances != len(members) {
+			if err := pm.scaleLocked(name, spec.Instances); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
+// stopReplaced stops the supervisors of the groups Apply replaces, those
+// with no active process, that are waiting to restart a process, and waits
+// for them to return. Otherwise they would run the old spec alongside the
+// new one.
+func (pm *ProcessManager) stopReplaced(specs []ProcessSpec) {
+	pm.mu.Lock()
+	var stopping []*ProcessInformation
+	for _, spec := range specs {
+		members := pm.membersLocked(spec.Name)
+		active := false
+		for _, pi := range members {
+			active = active || pi.isActive()
+		}
+		if active {
+			continue
+		}
+		for _, pi := range members {
+			if pi.supervised && pm.stopLocked(pi, false) == nil {
+				stopping = append(stopping, pi)
+			}
+		}
+	}
+	pm.mu.Unlock()
+
+	for _, pi := range stopping {
+		pm.waitExited(pi, stopTimeout)
+	}
+}
+
+// specsLocked returns one spec per process group.
+func (pm *ProcessManager) specsLocked() []ProcessSpec {
+	seen := make(map[string]bool)
+	specs := make([]ProcessSpec, 0, len(pm.processes))
+	for _, pi := range pm.processes {
+		if seen[pi.Spec.Name] {
+			continue
+		}
+		seen[pi.Spec.Name] = true
+		specs = append(specs, pi.Spec)
+	}
+	return specs
+}
+
+// Snapshot returns what a restarted daemon needs to bring back: the specs
+// of the process groups that weren't stopped by the user and those of the
+// jobs, sorted by name.
+func (pm *ProcessManager) Snapshot() ([]ProcessSpec, []JobSpec) {
+	pm.mu.Lock()
+	wanted := make(map[string]bool)
+	for _, pi := range pm.processes {
+		wanted[pi.Spec.Name] = wanted[pi.Spec.Name] || !pi.stopRequested
+	}
+	var specs []ProcessSpec
+	for _, spec := range pm.specsLocked() {
+		if wanted[spec.Name] {
+			specs = append(specs, spec)
+		}
+	}
+	pm.mu.Unlock()
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+
+	var jobs []JobSpec
+	for _, job := range pm.ListJobs() {
+		jobs = append(jobs, job.Spec)
+	}
+	return specs, jobs
+}
+
+func (pi *ProcessInformation) isActive() bool {
+	switch pi.Status {
+	case "starting", "waiting", "running":
+		return true
+	}
+	return false
+}
+
 func (pm *ProcessManager) GetProcess(name string) (*ProcessInformation, error) {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
@@ -179,19 +661,74 @@ func (pm *ProcessManager) GetProcess(name string) (*ProcessInformation, error) {
 	return pm.processes[name], nil
 }
 
-func (pm *ProcessManager) ListProcesses(verbose bool) (map[string]*ProcessInformation, error) {
+// ListProcesses returns a copy of every process, taken at once, that can
+// be read without holding the lock.
+func (pm *ProcessManager) ListProcesses() []ProcessInformation {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
-	return pm.processes, nil
+	processes := make([]ProcessInformation, 0, len(pm.processes))
+	for _, pi := range pm.processes {
+		processes = append(processes, *pi)
+	}
+	return processes
 }
 
-func (pm *ProcessManager) GetLogChannels() map[string]chan string {
+// Logs returns the output buffer of a process, or of the most recent run of
+// a job. For jobs, run selects a specific run by ID instead.
+func (pm *ProcessManager) Logs(name string, run int) (*LogBuffer, error) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	if job, ok := pm.jobs[name]; ok {
+		return job.logsLocked(run)
+	}
+	if task, ok := pm.tasks[name]; ok {
+		return task.Run.Logs, nil
+	}
+	if logs, ok := pm.logs[name]; ok {
+		return logs, nil
+	}
+	return nil, fmt.Errorf("no logs for process %s", name)
+}
 
-	return pm.logChannels
+func (pm *ProcessManager) nameTakenLocked(name string) bool {
+	if _, ok := pm.processes[name]; ok {
+		return true
+	}
+	if _, ok := pm.jobs[name]; ok {
+		return true
+	}
+	if _, ok := pm.tasks[name]; ok {
+		return true
+	}
+	return len(pm.membersLocked(name)) > 0
 }
 
+// RemoveProcess stops pi if it is still active and forgets it. The file
+// watcher of its group goes with the group's last instance.
 func (pm *ProcessManager) RemoveProcess(pi *ProcessInformation) error {
-	// should stop if not stopped and then remove
+	pm.mu.Lock()
+	if pi.removing || pm.processes[pi.Name] != pi {
+		pm.mu.Unlock()
+		return fmt.Errorf("process %q not found", pi.Name)
+	}
+	pi.removing = true
+	err := pm.stopLocked(pi, false)
+	pm.mu.Unlock()
+	if err == nil {
+		pm.waitExited(pi, stopTimeout)
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.deleteLocked(pi)
+	if len(pm.resolveLocked(pi.Spec.Name)) == 0 {
+		if w, ok := pm.watchers[pi.Spec.Name]; ok {
+			w.close()
+			delete(pm.watchers, pi.Spec.Name)
+		}
+	}
+	pm.events.Record(pi.Name, "removed", "process removed")
 	return nil
 }
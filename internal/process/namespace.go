@@ -0,0 +1,36 @@
+package process
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultNamespace is used for names that don't say which namespace they
+// belong to.
+const DefaultNamespace = "default"
+
+// Process, job and task names are qualified as "namespace/name" so that
+// the same name can be used in different namespaces of one daemon.
+
+// Qualify places name in namespace unless it is qualified already.
+func Qualify(namespace, name string) string {
+	if name == "" || strings.Contains(name, "/") {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// Namespace returns the namespace of a qualified name.
+func Namespace(name string) string {
+	namespace, _, _ := strings.Cut(name, "/")
+	return namespace
+}
+
+// ValidateName checks that a qualified name has exactly one namespace.
+func ValidateName(name string) error {
+	namespace, short, ok := strings.Cut(name, "/")
+	if !ok || namespace == "" || short == "" || strings.Contains(short, "/") {
+		return fmt.Errorf("invalid name %q, expected namespace/name", name)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package process
+
+import "testing"
+
+func TestQualify(t *testing.T) {
+	tests := []struct {
+		namespace string
+		name      string
+		want      string
+	}{
+		{"default", "web", "default/web"},
+		{"staging", "web", "staging/web"},
+		{"staging", "prod/web", "prod/web"},
+		{"staging", "", ""},
+		{"staging", "all", "staging/all"},
+	}
+	for _, tt := range tests {
+		if got := Qualify(tt.namespace, tt.name); got != tt.want {
+			t.Errorf("Qualify(%q, %q) = %q, want %q", tt.namespace, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"default/web", "default"},
+		{"staging/web-0", "staging"},
+		{"web", "web"},
+	}
+	for _, tt := range tests {
+		if got := Namespace(tt.name); got != tt.want {
+			t.Errorf("Namespace(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "default/web"},
+		{name: "staging/web-0"},
+		{name: "web", wantErr: true},
+		{name: "", wantErr: true},
+		{name: "/web", wantErr: true},
+		{name: "default/", wantErr: true},
+		{name: "a/b/c", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateName(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateName(%q) error = %v, want error %v", tt.name, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// ptyScrollback is how much raw output is replayed to a new attachment
+	// so it sees the current prompt.
+	ptyScrollback = 8 * 1024
+	defaultRows   = 24
+	defaultCols   = 80
+)
+
+// terminal is the pseudo-terminal a process started with TTY runs on. Its
+// output is fanned out to the attached clients.
+type terminal struct {
+	master *os.File
+
+	mu         sync.Mutex
+	scrollback []byte
+	subs       map[chan []byte]struct{}
+	closed     bool
+}
+
+// openTerminal allocates a pseudo-terminal and returns it with its slave
+// side, which becomes the child's stdin, stdout and stderr.
+func openTerminal() (*terminal, *os.File, error) {
+	fd, err := unix.Open("/dev/ptmx", unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open pty: %v", err)
+	}
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		unix.Close(fd)
+		return nil, nil, fmt.Errorf("unlock pty: %v", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		unix.Close(fd)
+		return nil, nil, fmt.Errorf("pty number: %v", err)
+	}
+	master := os.NewFile(uintptr(fd), "ptmx")
+	slave, err := os.OpenFile("/dev/pts/"+strconv.Itoa(n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+
+	t := &terminal{master: master, subs: make(map[chan []byte]struct{})}
+	t.Resize(defaultRows, defaultCols)
+	return t, slave, nil
+}
+
+// pump copies the terminal's output to the attached clients and hands it to
+// onOutput until the last process holding the slave side exits.
+func (t *terminal) pump(onOutput func([]byte)) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := t.master.Read(buf)
+		if n > 0 {
+			data := append([]byte(nil), buf[:n]...)
+			onOutput(data)
+			t.broadcast(data)
+		}
+		if err != nil {
+			// EIO once the slave side is closed
+			return
+		}
+	}
+}
+
+func (t *terminal) broadcast(data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.scrollback = append(t.scrollback, data...)
+	if over := len(t.scrollback) - ptyScrollback; over > 0 {
+		t.scrollback = t.scrollback[over:]
+	}
+	for ch := range t.subs {
+		select {
+		case ch <- data:
+		default:
+			// a client that can't keep up loses output rather than
+			// blocking the process
+		}
+	}
+}
+
+// Subscribe returns the recent output and a channel receiving new output
+// until the terminal is closed or cancel is called.
+func (t *terminal) Subscribe() ([]byte, <-chan []byte, func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ch := make(chan []byte, 256)
+	if t.closed {
+		close(ch)
+		return append([]byte(nil), t.scrollback...), ch, func() {}
+	}
+	t.subs[ch] = struct{}{}
+	cancel := func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if _, ok := t.subs[ch]; ok {
+			delete(t.subs, ch)
+			close(ch)
+		}
+	}
+	return append([]byte(nil), t.scrollback...), ch, cancel
+}
+
+func (t *terminal) Write(data []byte) (int, error) {
+	return t.master.Write(data)
+}
+
+func (t *terminal) Resize(rows, cols uint16) error {
+	return unix.IoctlSetWinsize(int(t.master.Fd()), unix.TIOCSWINSZ, &unix.Winsize{Row: rows, Col: cols})
+}
+
+// close ends every attachment and releases the master side.
+func (t *terminal) close() {
+	t.mu.Lock()
+	t.closed = true
+	for ch := range t.subs {
+		delete(t.subs, ch)
+		close(ch)
+	}
+	t.mu.Unlock()
+	t.master.Close()
+}
+
+// Attachment is a client connected to the terminal of a process.
+type Attachment struct {
+	// Scrollback is the output from just before attaching.
+	Scrollback []byte
+	// Output receives the process's output and is closed when it exits.
+	Output <-chan []byte
+	Detach func()
+	term   *terminal
+}
+
+func (a *Attachment) Write(data []byte) (int, error) {
+	return a.term.Write(data)
+}
+
+func (a *Attachment) Resize(rows, cols uint16) error {
+	return a.term.Resize(rows, cols)
+}
+
+// Attach connects to the terminal of a running process started with TTY.
+func (pm *ProcessManager) Attach(name string) (*Attachment, error) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pi, ok := pm.processes[name]
+	if !ok {
+		return nil, fmt.Errorf("process %q not found", name)
+	}
+	if !pi.Spec.TTY {
+		return nil, fmt.Errorf("process %q was not started with a tty", name)
+	}
+	if pi.Status != "running" || pi.term == nil {
+		return nil, fmt.Errorf("process %q is not running", name)
+	}
+	scrollback, output, detach := pi.term.Subscribe()
+	return &Attachment{Scrollback: scrollback, Output: output, Detach: detach, term: pi.term}, nil
+}
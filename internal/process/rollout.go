@@ -0,0 +1,164 @@
+package process
+
+import (
+	"fmt"
+	"time"
+)
+
+type RolloutOptions struct {
+	// BatchSize is how many instances are restarted at a time.
+	BatchSize int
+	// MinUptime is how long an instance without a health check has to stay
+	// up before it counts as ready.
+	MinUptime time.Duration
+	// ReadyTimeout bounds the wait for each batch to become ready.
+	ReadyTimeout time.Duration
+}
+
+func (opts RolloutOptions) withDefaults() RolloutOptions {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1
+	}
+	if opts.MinUptime <= 0 {
+		opts.MinUptime = 5 * time.Second
+	}
+	if opts.ReadyTimeout <= 0 {
+		opts.ReadyTimeout = 60 * time.Second
+	}
+	return opts
+}
+
+// resolveLocked returns the instances of the group called name, or the
+// single process registered under name.
+func (pm *ProcessManager) resolveLocked(name string) []*ProcessInformation {
+	if members := pm.membersLocked(name); len(members) > 0 {
+		return members
+	}
+	if pi, ok := pm.processes[name]; ok {
+		return []*ProcessInformation{pi}
+	}
+	return nil
+}
+
+// Restart stops every instance of name and starts them again. exitReason is
+// recorded as the reason the stopped runs ended, one of the ExitReason
+// restart constants, and message describes the restart.
+func (pm *ProcessManager) Restart(name string, force bool, exitReason, message string) error {
+	pm.mu.Lock()
+	targets := pm.resolveLocked(name)
+	pm.mu.Unlock()
+	if len(targets) == 0 {
+		return fmt.Errorf("process %q not found", name)
+	}
+
+	for _, pi := range targets {
+		pm.events.Record(pi.Name, "restart", "%s", message)
+		pm.stopAndWait(pi, force, exitReason)
+	}
+	pm.mu.Lock()
+	for _, pi := range targets {
+		pi.Restarts++
+		pi.RestartReason = message
+		pm.launch(pi)
+	}
+	pm.mu.Unlock()
+	return nil
+}
+
+// RollingRestart restarts the instances of name a batch at a time, waiting
+// for each batch to become ready before moving on. The rollout is aborted
+// as soon as a restarted instance fails to become ready. progress, if not
+// nil, is called with a line describing each step.
+func (pm *ProcessManager) RollingRestart(name string, opts RolloutOptions, progress func(string)) error {
+	pm.mu.Lock()
+	targets := pm.resolveLocked(name)
+	pm.mu.Unlock()
+	if len(targets) == 0 {
+		return fmt.Errorf("process %q not found", name)
+	}
+	return pm.RollingRestartProcesses(name, targets, opts, progress)
+}
+
+// RollingRestartProcesses is RollingRestart for an explicit list of
+// processes, such as the result of Select. label names them in errors.
+func (pm *ProcessManager) RollingRestartProcesses(label string, targets []*ProcessInformation, opts RolloutOptions, progress func(string)) error {
+	opts = opts.withDefaults()
+	if progress == nil {
+		progress = func(string) {}
+	}
+
+	for start := 0; start < len(targets); start += opts.BatchSize {
+		end := min(start+opts.BatchSize, len(targets))
+		batch := targets[start:end]
+
+		for _, pi := range batch {
+			progress(fmt.Sprintf("restarting %s", pi.Name))
+			pm.events.Record(pi.Name, "restart", "rolling restart")
+			pm.stopAndWait(pi, false, ExitReasonRestarted)
+			pm.mu.Lock()
+			pi.Restarts++
+			pi.RestartReason = "rolling restart"
+			pm.launch(pi)
+			pm.mu.Unlock()
+		}
+		for _, pi := range batch {
+			if err := pm.waitReady(pi, opts); err != nil {
+				return fmt.Errorf("rollout of %s aborted: %v", label, err)
+			}
+			progress(fmt.Sprintf("%s is ready", pi.Name))
+		}
+	}
+	return nil
+}
+
+// stopAndWait stops pi for exitReason and waits for its supervisor to
+// return, so that it can be launched again.
+func (pm *ProcessManager) stopAndWait(pi *ProcessInformation, force bool, exitReason string) {
+	pm.mu.Lock()
+	if pi.Status == "running" {
+		pi.killReason = exitReason
+	}
+	err := pm.stopLocked(pi, force)
+	pm.mu.Unlock()
+	if err == nil {
+		pm.waitExited(pi, stopTimeout)
+	}
+}
+
+// waitReady blocks until pi passes its health check, or stays up for
+// MinUptime if it has none. Exiting or restarting in the meantime counts as
+// a failure.
+func (pm *ProcessManager) waitReady(pi *ProcessInformation, opts RolloutOptions) error {
+	deadline := time.Now().Add(opts.ReadyTimeout)
+	var startedAt time.Time
+	for {
+		pm.mu.Lock()
+		status := pi.Status
+		healthy := pi.Healthy
+		hasHealthCheck := pi.Spec.HealthCheck != nil
+		current := pi.StartedAt
+		pm.mu.Unlock()
+
+		switch status {
+		case "exited", "failed", "stopped":
+			return fmt.Errorf("instance %s %s before becoming ready", pi.Name, status)
+		case "running":
+			if startedAt.IsZero() {
+				startedAt = current
+			} else if !current.Equal(startedAt) {
+				return fmt.Errorf("instance %s restarted before becoming ready", pi.Name)
+			}
+			if hasHealthCheck && healthy {
+				return nil
+			}
+			if !hasHealthCheck && time.Since(startedAt) >= opts.MinUptime {
+				return nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("instance %s did not become ready within %s", pi.Name, opts.ReadyTimeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
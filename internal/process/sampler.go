@@ -0,0 +1,174 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sampleInterval = 5 * time.Second
+	// clockTicks is USER_HZ, which is 100 on every Linux platform we run on.
+	clockTicks = 100
+)
+
+// Stats is the latest resource sample of a process. Processes in a cgroup
+// are measured through it, which includes their children.
+type Stats struct {
+	CPUPercent  float64
+	MemoryBytes uint64
+	SampledAt   time.Time
+}
+
+// sampleState carries what the sampler needs between two samples.
+type sampleState struct {
+	cpuUsec    uint64
+	at         time.Time
+	memOver    time.Time
+	cpuOver    time.Time
+	restarting bool
+}
+
+func (pm *ProcessManager) runSampler() {
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		pm.sampleAll()
+	}
+}
+
+func (pm *ProcessManager) sampleAll() {
+	pm.mu.Lock()
+	var running []*ProcessInformation
+	for _, pi := range pm.processes {
+		if pi.Status == "running" && !pi.stopRequested {
+			running = append(running, pi)
+		}
+	}
+	pm.mu.Unlock()
+
+	for _, pi := range running {
+		pm.sample(pi)
+	}
+}
+
+func (pm *ProcessManager) sample(pi *ProcessInformation) {
+	pm.mu.Lock()
+	pid, cgroup := pi.PID, pi.cgroup
+	pm.mu.Unlock()
+
+	cpuUsec, memory, err := readUsage(pid, cgroup)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	state := &pi.sampling
+	if !state.at.IsZero() && cpuUsec >= state.cpuUsec {
+		elapsed := now.Sub(state.at).Microseconds()
+		if elapsed > 0 {
+			pi.Stats.CPUPercent = float64(cpuUsec-state.cpuUsec) / float64(elapsed) * 100
+		}
+	}
+	pi.Stats.MemoryBytes = memory
+	pi.Stats.SampledAt = now
+	state.cpuUsec, state.at = cpuUsec, now
+
+	pm.checkThresholdsLocked(pi, now)
+}
+
+// checkThresholdsLocked restarts pi once it has been above its memory or
+// CPU threshold for the configured duration.
+func (pm *ProcessManager) checkThresholdsLocked(pi *ProcessInformation, now time.Time) {
+	spec := pi.Spec
+	state := &pi.sampling
+	if state.restarting {
+		return
+	}
+
+	var reason string
+	if spec.MaxMemory > 0 && pi.Stats.MemoryBytes > spec.MaxMemory {
+		if state.memOver.IsZero() {
+			state.memOver = now
+		}
+		if now.Sub(state.memOver) >= spec.ThresholdDuration {
+			reason = fmt.Sprintf("memory threshold exceeded: %d bytes > %d bytes for %s",
+				pi.Stats.MemoryBytes, spec.MaxMemory, now.Sub(state.memOver).Round(time.Second))
+		}
+	} else {
+		state.memOver = time.Time{}
+	}
+
+	// the first sample has no CPU figure yet
+	if spec.MaxCPU > 0 && !pi.Stats.SampledAt.IsZero() && pi.Stats.CPUPercent > spec.MaxCPU {
+		if state.cpuOver.IsZero() {
+			state.cpuOver = now
+		}
+		if reason == "" && now.Sub(state.cpuOver) >= spec.ThresholdDuration {
+			reason = fmt.Sprintf("cpu threshold exceeded: %.1f%% > %.1f%% for %s",
+				pi.Stats.CPUPercent, spec.MaxCPU, now.Sub(state.cpuOver).Round(time.Second))
+		}
+	} else {
+		state.cpuOver = time.Time{}
+	}
+
+	if reason == "" {
+		return
+	}
+	state.restarting = true
+	fmt.Printf("process %s: %s\n", pi.Name, reason)
+	go func() {
+		if err := pm.Restart(pi.Name, false, ExitReasonThresholdExceeded, reason); err != nil {
+			fmt.Printf("process %s: threshold restart failed: %v\n", pi.Name, err)
+		}
+	}()
+}
+
+// readUsage returns the total CPU time in microseconds and the memory in use
+// by a process, from its cgroup if it has one and from /proc otherwise.
+func readUsage(pid int, cgroup string) (uint64, uint64, error) {
+	if cgroup != "" {
+		memory, err := readUint(filepath.Join(cgroup, "memory.current"))
+		if err != nil {
+			return 0, 0, err
+		}
+		stat, err := os.ReadFile(filepath.Join(cgroup, "cpu.stat"))
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, line := range strings.Split(string(stat), "\n") {
+			if value, ok := strings.CutPrefix(line, "usage_usec "); ok {
+				usec, err := strconv.ParseUint(value, 10, 64)
+				return usec, memory, err
+			}
+		}
+		return 0, memory, nil
+	}
+
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+	// the command name may contain spaces, so split after its closing paren
+	end := strings.LastIndexByte(string(stat), ')')
+	if end < 0 {
+		return 0, 0, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+	fields := strings.Fields(string(stat)[end+1:])
+	// fields[0] is the state (field 3), so utime and stime (14, 15) are 11, 12
+	if len(fields) < 22 {
+		return 0, 0, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	rssPages, _ := strconv.ParseUint(fields[21], 10, 64)
+
+	cpuUsec := (utime + stime) * 1_000_000 / clockTicks
+	return cpuUsec, rssPages * uint64(os.Getpagesize()), nil
+}
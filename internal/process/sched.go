@@ -0,0 +1,174 @@
+package process
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// IO scheduling classes as understood by ioprio_set(2).
+const (
+	IOClassNone       = ""
+	IOClassRealtime   = "realtime"
+	IOClassBestEffort = "best-effort"
+	IOClassIdle       = "idle"
+)
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+)
+
+var ioClasses = []string{IOClassNone, IOClassRealtime, IOClassBestEffort, IOClassIdle}
+
+// Scheduling holds the CPU and IO priority of a process. The child applies
+// it to itself before it execs the command (see spawnThrough), so it is
+// inherited by every thread and process the command starts.
+type Scheduling struct {
+	// Nice is the niceness from -20 to 19; nil keeps the daemon's.
+	Nice *int
+	// IOClass and IOLevel (0-7, lower is more important) set the IO
+	// priority; an empty class keeps the daemon's.
+	IOClass string
+	IOLevel int
+	// CPUs restricts the process to these CPUs; empty means any.
+	CPUs []int
+}
+
+func (s *Scheduling) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if s.Nice != nil && (*s.Nice < -20 || *s.Nice > 19) {
+		return fmt.Errorf("nice must be between -20 and 19, got %d", *s.Nice)
+	}
+	if ioClassValue(s.IOClass) < 0 {
+		return fmt.Errorf("unknown io class %q (realtime|best-effort|idle)", s.IOClass)
+	}
+	if s.IOLevel < 0 || s.IOLevel > 7 {
+		return fmt.Errorf("io level must be between 0 and 7, got %d", s.IOLevel)
+	}
+	for _, cpu := range s.CPUs {
+		if cpu < 0 || cpu >= 1024 {
+			return fmt.Errorf("invalid cpu %d", cpu)
+		}
+	}
+	return nil
+}
+
+func ioClassValue(class string) int {
+	for i, c := range ioClasses {
+		if c == class {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyScheduling sets the priorities and affinity of the calling thread,
+// which is all the kernel applies them to.
+func applyScheduling(s *Scheduling) error {
+	if s == nil {
+		return nil
+	}
+	if s.Nice != nil {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, 0, *s.Nice); err != nil {
+			return fmt.Errorf("set nice: %v", err)
+		}
+	}
+	if s.IOClass != IOClassNone {
+		prio := ioClassValue(s.IOClass)<<ioprioClassShift | s.IOLevel
+		_, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, 0, uintptr(prio))
+		if errno != 0 {
+			return fmt.Errorf("set io priority: %v", errno)
+		}
+	}
+	if len(s.CPUs) > 0 {
+		var set unix.CPUSet
+		for _, cpu := range s.CPUs {
+			set.Set(cpu)
+		}
+		if err := unix.SchedSetaffinity(0, &set); err != nil {
+			return fmt.Errorf("set cpu affinity: %v", err)
+		}
+	}
+	return nil
+}
+
+// EffectiveScheduling reads back the priorities and affinity the kernel
+// reports for pid.
+func EffectiveScheduling(pid int) (*Scheduling, error) {
+	// the raw syscall returns 20-nice so that the result is never negative
+	prio, err := unix.Getpriority(unix.PRIO_PROCESS, pid)
+	if err != nil {
+		return nil, err
+	}
+	nice := 20 - prio
+	s := &Scheduling{Nice: &nice}
+
+	ioprio, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(pid), 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	if class := int(ioprio >> ioprioClassShift); class > 0 && class < len(ioClasses) {
+		s.IOClass = ioClasses[class]
+		s.IOLevel = int(ioprio & (1<<ioprioClassShift - 1))
+	}
+
+	var set unix.CPUSet
+	if err := unix.SchedGetaffinity(pid, &set); err != nil {
+		return nil, err
+	}
+	for cpu := 0; cpu < len(set)*64; cpu++ {
+		if set.IsSet(cpu) {
+			s.CPUs = append(s.CPUs, cpu)
+		}
+	}
+	return s, nil
+}
+
+// ParseCPUList parses a list like "0-3,6" as used by taskset(1).
+func ParseCPUList(list string) ([]int, error) {
+	var cpus []int
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		lo, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu list %q", list)
+		}
+		hi := lo
+		if isRange {
+			if hi, err = strconv.Atoi(last); err != nil || hi < lo {
+				return nil, fmt.Errorf("invalid cpu list %q", list)
+			}
+		}
+		for cpu := lo; cpu <= hi; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// FormatCPUList is the inverse of ParseCPUList, collapsing runs into ranges.
+func FormatCPUList(cpus []int) string {
+	var parts []string
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", cpus[i], cpus[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(cpus[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
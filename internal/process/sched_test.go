@@ -0,0 +1,71 @@
+package process
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []int
+		wantErr bool
+	}{
+		{list: "", want: nil},
+		{list: "3", want: []int{3}},
+		{list: "0-3", want: []int{0, 1, 2, 3}},
+		{list: "0-2,6, 8-9", want: []int{0, 1, 2, 6, 8, 9}},
+		{list: "1,,2,", want: []int{1, 2}},
+		{list: "4-4", want: []int{4}},
+		{list: "3-1", wantErr: true},
+		{list: "-1", wantErr: true},
+		{list: "1-", wantErr: true},
+		{list: "a", wantErr: true},
+		{list: "0-2-4", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			got, err := ParseCPUList(tt.list)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseCPUList(%q) = %v, want an error", tt.list, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCPUList(%q) error = %v", tt.list, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseCPUList(%q) = %v, want %v", tt.list, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatCPUList(t *testing.T) {
+	tests := []struct {
+		cpus []int
+		want string
+	}{
+		{nil, ""},
+		{[]int{5}, "5"},
+		{[]int{0, 1}, "0-1"},
+		{[]int{0, 1, 2, 3}, "0-3"},
+		{[]int{0, 2, 4}, "0,2,4"},
+		{[]int{0, 1, 2, 6, 8, 9}, "0-2,6,8-9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := FormatCPUList(tt.cpus); got != tt.want {
+				t.Errorf("FormatCPUList(%v) = %q, want %q", tt.cpus, got, tt.want)
+			}
+			if len(tt.cpus) == 0 {
+				return
+			}
+			back, err := ParseCPUList(tt.want)
+			if err != nil || !slices.Equal(back, tt.cpus) {
+				t.Errorf("ParseCPUList(%q) = %v, %v, want %v", tt.want, back, err, tt.cpus)
+			}
+		})
+	}
+}
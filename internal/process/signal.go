@@ -0,0 +1,63 @@
+package process
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ParseSignal accepts a signal by number or by name, with or without the
+// SIG prefix and in any case: "10", "USR1", "sigusr1".
+func ParseSignal(s string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n <= 0 || n > 64 {
+			return 0, fmt.Errorf("invalid signal number %d", n)
+		}
+		return syscall.Signal(n), nil
+	}
+	name := strings.ToUpper(s)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if sig := unix.SignalNum(name); sig != 0 {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("unknown signal %q", s)
+}
+
+// Signal delivers sig to every running instance of name, or to their whole
+// process groups if group is set. It returns one line per delivery.
+func (pm *ProcessManager) Signal(name string, sig syscall.Signal, group bool) ([]string, error) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	targets := pm.resolveLocked(name)
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("process %q not found", name)
+	}
+
+	var delivered []string
+	for _, pi := range targets {
+		if pi.Status != "running" || pi.Cmd == nil || pi.Cmd.Process == nil {
+			continue
+		}
+		pid, target := pi.PID, fmt.Sprintf("pid %d", pi.PID)
+		if group {
+			// every child is the leader of its own process group
+			pid, target = -pi.PID, fmt.Sprintf("process group %d", pi.PID)
+		}
+		if err := unix.Kill(pid, sig); err != nil {
+			return delivered, fmt.Errorf("signal %s: %v", pi.Name, err)
+		}
+		message := fmt.Sprintf("sent %s to %s", signalName(sig), target)
+		pm.events.Record(pi.Name, "signal", "%s", message)
+		delivered = append(delivered, fmt.Sprintf("%s: %s", pi.Name, message))
+	}
+	if len(delivered) == 0 {
+		return nil, fmt.Errorf("process %q is not running", name)
+	}
+	return delivered, nil
+}
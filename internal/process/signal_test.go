@@ -0,0 +1,46 @@
+package process
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    syscall.Signal
+		wantErr bool
+	}{
+		{in: "10", want: syscall.SIGUSR1},
+		{in: "9", want: syscall.SIGKILL},
+		{in: "64", want: syscall.Signal(64)},
+		{in: "USR1", want: syscall.SIGUSR1},
+		{in: "SIGUSR1", want: syscall.SIGUSR1},
+		{in: "sigusr1", want: syscall.SIGUSR1},
+		{in: "hup", want: syscall.SIGHUP},
+		{in: "Term", want: syscall.SIGTERM},
+		{in: "0", wantErr: true},
+		{in: "-1", wantErr: true},
+		{in: "65", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "SIG", wantErr: true},
+		{in: "bogus", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSignal(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseSignal(%q) = %v, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSignal(%q) error = %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSignal(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
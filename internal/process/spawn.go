@@ -0,0 +1,89 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+// spawnEnv passes spawnSettings to a child started through spawnThrough.
+const spawnEnv = "GOPM_SPAWN"
+
+// spawnSettings are what a child applies to itself before it execs the
+// command.
+type spawnSettings struct {
+	// Path is the command, resolved by the daemon.
+	Path       string
+	Limits     *ResourceLimits `json:",omitempty"`
+	Scheduling *Scheduling     `json:",omitempty"`
+}
+
+func (s spawnSettings) needed() bool {
+	return s.Limits.hasRlimits() || s.Scheduling != nil
+}
+
+// spawnThrough makes cmd start as a copy of the daemon's own binary, which
+// applies settings to itself and then execs the command. Go can't run code
+// in the child between fork and exec, and settings applied after the
+// command has started would miss what it does first, such as opening files
+// or starting threads. Failures to apply them are written to the command's
+// stderr.
+func spawnThrough(cmd *exec.Cmd, settings spawnSettings) {
+	if !settings.needed() {
+		return
+	}
+	path := cmd.Path
+	if !filepath.IsAbs(path) && cmd.Dir != "" {
+		path = filepath.Join(cmd.Dir, path)
+	}
+	if _, err := exec.LookPath(path); cmd.Err != nil || err != nil {
+		// left for Start to report
+		return
+	}
+	settings.Path = cmd.Path
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return
+	}
+	// the running binary, even if it has been replaced on disk since
+	cmd.Path = "/proc/self/exe"
+	cmd.Env = append(cmd.Env, spawnEnv+"="+string(data))
+}
+
+func init() {
+	data, ok := os.LookupEnv(spawnEnv)
+	if !ok {
+		return
+	}
+	// settings that belong to a thread only carry over to the command from
+	// the thread that execs it
+	runtime.LockOSThread()
+
+	var settings spawnSettings
+	if err := json.Unmarshal([]byte(data), &settings); err != nil {
+		fmt.Fprintf(os.Stderr, "gopm: invalid %s: %v\n", spawnEnv, err)
+		os.Exit(127)
+	}
+	if err := applyRlimits(settings.Limits); err != nil {
+		fmt.Fprintf(os.Stderr, "gopm: %v\n", err)
+	}
+	if err := applyScheduling(settings.Scheduling); err != nil {
+		fmt.Fprintf(os.Stderr, "gopm: %v\n", err)
+	}
+
+	env := os.Environ()
+	for i, kv := range env {
+		if strings.HasPrefix(kv, spawnEnv+"=") {
+			env = append(env[:i], env[i+1:]...)
+			break
+		}
+	}
+	err := syscall.Exec(settings.Path, os.Args, env)
+	fmt.Fprintf(os.Stderr, "gopm: exec %s: %v\n", settings.Path, err)
+	os.Exit(127)
+}
@@ -0,0 +1,79 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+// runLogged runs spec to completion and returns its output.
+func runLogged(t *testing.T, spec ProcessSpec) []string {
+	t.Helper()
+	pm := NewProcessManager()
+	pi, err := pm.StartProcess(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm.waitExited(pi, 5*time.Second)
+	logs, err := pm.Logs(spec.Name, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logs.Lines()
+}
+
+func TestSpawnRlimits(t *testing.T) {
+	noFile, core := uint64(99), uint64(0)
+	got := runLogged(t, ProcessSpec{
+		Name:    "test/rlimits",
+		Command: "sh",
+		Args:    []string{"-c", `ulimit -n; ulimit -c; echo "${GOPM_SPAWN-unset}"`},
+		Limits:  &ResourceLimits{NoFile: &noFile, Core: &core},
+	})
+	want := []string{"99", "0", "unset"}
+	if !slices.Equal(got, want) {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestSpawnScheduling(t *testing.T) {
+	nice := 5
+	want := &Scheduling{Nice: &nice, IOClass: IOClassIdle, CPUs: []int{0}}
+	pm := NewProcessManager()
+	pi, err := pm.StartProcess(ProcessSpec{
+		Name:       "test/scheduling",
+		Command:    "sleep",
+		Args:       []string{"10"},
+		Scheduling: want,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pm.RemoveProcess(pi)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pm.mu.Lock()
+		status, pid := pi.Status, pi.PID
+		pm.mu.Unlock()
+		// exec'd once the spawning copy of the test binary is gone
+		comm, _ := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+		if status == "running" && string(comm) == "sleep\n" {
+			got, err := EffectiveScheduling(pid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got.Nice != nice || got.IOClass != want.IOClass || !slices.Equal(got.CPUs, want.CPUs) {
+				t.Errorf("scheduling = nice %d, io class %q, cpus %v, want nice %d, io class %q, cpus %v",
+					*got.Nice, got.IOClass, got.CPUs, nice, want.IOClass, want.CPUs)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("process is %s", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
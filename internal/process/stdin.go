@@ -0,0 +1,62 @@
+package process
+
+import (
+	"fmt"
+	"io"
+)
+
+// stdinLocked returns where input for the process called name goes: its
+// stdin pipe, or its terminal if it runs on one.
+func (pm *ProcessManager) stdinLocked(name string) (io.Writer, *ProcessInformation, error) {
+	pi, ok := pm.processes[name]
+	if !ok {
+		if members := pm.membersLocked(name); len(members) > 0 {
+			return nil, nil, fmt.Errorf("%q has several instances, name one of them, e.g. %s", name, members[0].Name)
+		}
+		return nil, nil, fmt.Errorf("process %q not found", name)
+	}
+	if !pi.Spec.Stdin && !pi.Spec.TTY {
+		return nil, nil, fmt.Errorf("process %q was not started with stdin or a tty", name)
+	}
+	if pi.Status != "running" {
+		return nil, nil, fmt.Errorf("process %q is not running", name)
+	}
+	if pi.term != nil {
+		return pi.term, pi, nil
+	}
+	if pi.stdin == nil {
+		return nil, nil, fmt.Errorf("stdin of %q is closed", name)
+	}
+	return pi.stdin, pi, nil
+}
+
+// WriteStdin writes data to the stdin of a running process.
+func (pm *ProcessManager) WriteStdin(name string, data []byte) error {
+	pm.mu.Lock()
+	w, _, err := pm.stdinLocked(name)
+	pm.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	// a process that doesn't read can block the write, so pm.mu isn't held
+	_, err = w.Write(data)
+	return err
+}
+
+// CloseStdin closes the stdin pipe of a running process so that it sees
+// end of file. It is reopened when the process restarts.
+func (pm *ProcessManager) CloseStdin(name string) error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	_, pi, err := pm.stdinLocked(name)
+	if err != nil {
+		return err
+	}
+	if pi.stdin == nil {
+		return fmt.Errorf("process %q runs on a tty, its input can't be closed", name)
+	}
+	err = pi.stdin.Close()
+	pi.stdin = nil
+	return err
+}
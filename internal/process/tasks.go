@@ -0,0 +1,114 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Task is a command that is expected to exit. Its result and logs are kept
+// after it finishes until it is removed or TTL passes.
+type Task struct {
+	Spec JobSpec
+	Run  *JobRun
+	// TTL is how long the result is kept after the task finishes; zero
+	// keeps it until the task is removed.
+	TTL time.Duration
+}
+
+// RunTask starts a one-shot task. A finished task with the same name is
+// replaced; a running one is an error.
+func (pm *ProcessManager) RunTask(spec JobSpec, ttl time.Duration) (*Task, error) {
+	if spec.Name == "" || spec.Command == "" {
+		return nil, fmt.Errorf("task needs a name and a command")
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	if old, ok := pm.tasks[spec.Name]; ok {
+		if old.Run.Status == "running" {
+			return nil, fmt.Errorf("task %q is still running", spec.Name)
+		}
+		delete(pm.tasks, spec.Name)
+	}
+	if pm.nameTakenLocked(spec.Name) {
+		return nil, fmt.Errorf("process with name %q already exists", spec.Name)
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if spec.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), spec.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	task := &Task{
+		Spec: spec,
+		TTL:  ttl,
+		Run: &JobRun{
+			ID:        1,
+			Status:    "running",
+			StartedAt: time.Now(),
+			Logs:      NewLogBuffer(logBufferSize),
+			cancel:    cancel,
+		},
+	}
+	pm.tasks[spec.Name] = task
+
+	go func() {
+		pm.executeRun(ctx, "task "+spec.Name, spec, task.Run)
+		if ttl > 0 {
+			time.AfterFunc(ttl, func() {
+				pm.mu.Lock()
+				defer pm.mu.Unlock()
+				if pm.tasks[spec.Name] == task {
+					delete(pm.tasks, spec.Name)
+				}
+			})
+		}
+	}()
+	return task, nil
+}
+
+// RemoveTask forgets a task, killing it first if it is still running.
+func (pm *ProcessManager) RemoveTask(name string) error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	task, ok := pm.tasks[name]
+	if !ok {
+		return fmt.Errorf("task %q not found", name)
+	}
+	task.Run.cancel()
+	delete(pm.tasks, name)
+	return nil
+}
+
+// TaskResult returns a snapshot of a task's run.
+func (pm *ProcessManager) TaskResult(name string) (JobRun, error) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	task, ok := pm.tasks[name]
+	if !ok {
+		return JobRun{}, fmt.Errorf("task %q not found", name)
+	}
+	return *task.Run, nil
+}
+
+// ListTasks returns a snapshot of every retained task, sorted by name.
+func (pm *ProcessManager) ListTasks() []Task {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	tasks := make([]Task, 0, len(pm.tasks))
+	for _, task := range pm.tasks {
+		run := *task.Run
+		tasks = append(tasks, Task{Spec: task.Spec, Run: &run, TTL: task.TTL})
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Spec.Name < tasks[j].Spec.Name })
+	return tasks
+}
@@ -0,0 +1,48 @@
+package process
+
+import "fmt"
+
+// Validate checks process specs and jobs the way applying them would,
+// without touching any state. Dependencies on processes that aren't among
+// specs are allowed, as they may already run in the daemon; they are
+// returned so that callers can point them out.
+func Validate(specs []ProcessSpec, jobs []JobSpec) ([]Dependency, error) {
+	names := make(map[string]bool, len(specs)+len(jobs))
+	for _, spec := range specs {
+		if names[spec.Name] {
+			return nil, fmt.Errorf("process %q is defined twice", spec.Name)
+		}
+		names[spec.Name] = true
+		switch spec.AutoRestart {
+		case "", "never", "always", "on-failure":
+		default:
+			return nil, fmt.Errorf("unknown restart policy %q for %q (expected never|always|on-failure)", spec.AutoRestart, spec.Name)
+		}
+	}
+	for _, job := range jobs {
+		if names[job.Name] {
+			return nil, fmt.Errorf("name %q is used twice", job.Name)
+		}
+		names[job.Name] = true
+		if err := job.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	// stand-ins for the external dependencies let startOrder find cycles
+	var external []Dependency
+	all := append([]ProcessSpec(nil), specs...)
+	for _, spec := range specs {
+		for _, dep := range spec.DependsOn {
+			if !names[dep.Name] {
+				names[dep.Name] = true
+				external = append(external, dep)
+				all = append(all, ProcessSpec{Name: dep.Name})
+			}
+		}
+	}
+	if _, err := startOrder(all); err != nil {
+		return nil, err
+	}
+	return external, nil
+}
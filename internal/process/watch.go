@@ -0,0 +1,232 @@
+package process
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const defaultWatchDebounce = 500 * time.Millisecond
+
+type WatchSpec struct {
+	// Paths are watched recursively; relative paths are resolved against
+	// the process's working directory.
+	Paths []string
+	// Ignore holds glob patterns matched against every path component and
+	// against the path relative to the watched root.
+	Ignore []string
+	// Debounce is how long the tree has to be quiet before restarting.
+	Debounce time.Duration
+}
+
+const watchMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_CLOSE_WRITE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE_SELF
+
+// watcher restarts a process group when files under its paths change.
+type watcher struct {
+	pm    *ProcessManager
+	name  string
+	spec  WatchSpec
+	fd    int
+	file  *os.File
+	mu    sync.Mutex
+	dirs  map[int]string
+	roots []string
+}
+
+// watchLocked starts watching for spec if it asks for it, replacing any
+// previous watcher of the same process.
+func (pm *ProcessManager) watchLocked(spec ProcessSpec) error {
+	if old, ok := pm.watchers[spec.Name]; ok {
+		old.close()
+		delete(pm.watchers, spec.Name)
+	}
+	if spec.Watch == nil || len(spec.Watch.Paths) == 0 {
+		return nil
+	}
+
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("inotify: %v", err)
+	}
+	w := &watcher{
+		pm:   pm,
+		name: spec.Name,
+		spec: *spec.Watch,
+		fd:   fd,
+		// reads through os.File go via the runtime poller, so closing the
+		// file unblocks them
+		file: os.NewFile(uintptr(fd), "inotify"),
+		dirs: make(map[int]string),
+	}
+	if w.spec.Debounce <= 0 {
+		w.spec.Debounce = defaultWatchDebounce
+	}
+
+	for _, path := range w.spec.Paths {
+		if !filepath.IsAbs(path) && spec.Cwd != "" {
+			path = filepath.Join(spec.Cwd, path)
+		}
+		path = filepath.Clean(path)
+		w.roots = append(w.roots, path)
+		if err := w.addTree(path); err != nil {
+			w.close()
+			return err
+		}
+	}
+
+	pm.watchers[spec.Name] = w
+	go w.run()
+	return nil
+}
+
+func (w *watcher) close() {
+	w.file.Close()
+}
+
+// addTree watches dir and every directory below it that isn't ignored.
+func (w *watcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			// directories can vanish while we walk
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != dir && w.ignored(path) {
+			return filepath.SkipDir
+		}
+
+		wd, err := unix.InotifyAddWatch(w.fd, path, watchMask)
+		if err != nil {
+			return fmt.Errorf("watch %s: %v", path, err)
+		}
+		w.mu.Lock()
+		w.dirs[wd] = path
+		w.mu.Unlock()
+		return nil
+	})
+}
+
+func (w *watcher) ignored(path string) bool {
+	rel := path
+	for _, root := range w.roots {
+		if r, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(r, "..") {
+			rel = r
+			break
+		}
+	}
+	for _, pattern := range w.spec.Ignore {
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+		for _, part := range strings.Split(rel, string(filepath.Separator)) {
+			if ok, _ := filepath.Match(pattern, part); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// run reads inotify events until the watcher is closed, restarting the
+// process once changes have settled for the debounce period.
+func (w *watcher) run() {
+	changes := make(chan string)
+	go func() {
+		defer close(changes)
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := w.file.Read(buf)
+			if err != nil {
+				return
+			}
+			for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+				event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(event.Len)]
+				offset += unix.SizeofInotifyEvent + int(event.Len)
+
+				w.mu.Lock()
+				dir, ok := w.dirs[int(event.Wd)]
+				if event.Mask&unix.IN_IGNORED != 0 {
+					delete(w.dirs, int(event.Wd))
+				}
+				w.mu.Unlock()
+				if !ok {
+					continue
+				}
+
+				path := dir
+				if name := strings.TrimRight(string(nameBytes), "\x00"); name != "" {
+					path = filepath.Join(dir, name)
+				}
+				if w.ignored(path) {
+					continue
+				}
+				if event.Mask&unix.IN_ISDIR != 0 && event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+					w.addTree(path)
+				}
+				if event.Mask&watchMask != 0 {
+					changes <- path
+				}
+			}
+		}
+	}()
+
+	var trigger string
+	changed := make(map[string]bool)
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	for {
+		select {
+		case path, ok := <-changes:
+			if !ok {
+				timer.Stop()
+				return
+			}
+			if len(changed) == 0 {
+				trigger = path
+			}
+			changed[path] = true
+			timer.Reset(w.spec.Debounce)
+
+		case <-timer.C:
+			w.restart(trigger, len(changed))
+			clear(changed)
+		}
+	}
+}
+
+func (w *watcher) restart(trigger string, count int) {
+	w.pm.mu.Lock()
+	active := false
+	for _, pi := range w.pm.membersLocked(w.name) {
+		active = active || pi.isActive()
+	}
+	w.pm.mu.Unlock()
+	if !active {
+		return
+	}
+
+	message := fmt.Sprintf("%s changed, restarting", trigger)
+	if count > 1 {
+		message = fmt.Sprintf("%s and %d other file(s) changed, restarting", trigger, count-1)
+	}
+	fmt.Printf("process %s: %s\n", w.name, message)
+	w.pm.events.Record(w.name, "watch", "%s", message)
+
+	if err := w.pm.Restart(w.name, false, ExitReasonFileChanged, message); err != nil {
+		fmt.Printf("process %s: restart after file change failed: %v\n", w.name, err)
+	}
+}
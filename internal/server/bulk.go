@@ -0,0 +1,116 @@
+package server
+
+import (
+	"fmt"
+
+	pm "github.com/brianykl/gopm/internal/process"
+	pb "github.com/brianykl/gopm/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// isBulk tells whether a request selects processes rather than naming one.
+func isBulk(name, selector string) bool {
+	return selector != "" || pm.IsPattern(name)
+}
+
+// selectProcesses returns the processes matching name and selector.
+func (pms *ProcessManagerServer) selectProcesses(name, selector string) ([]*pm.ProcessInformation, error) {
+	sel, err := pm.ParseSelector(selector)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	targets, err := pms.manager.Select(name, sel)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return targets, nil
+}
+
+// bulk runs op for every process matching name and selector and reports
+// the outcome per process. verb describes a successful op, e.g. "stopped".
+func (pms *ProcessManagerServer) bulk(name, selector, verb string, op func(*pm.ProcessInformation) error) (*pb.ProcessResponse, error) {
+	targets, err := pms.selectProcesses(name, selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, status.Error(codes.NotFound, "no processes match")
+	}
+
+	res := &pb.ProcessResponse{Success: true}
+	failed := 0
+	for _, pi := range targets {
+		result := &pb.ProcessResult{Name: pi.Name, Success: true, Message: verb}
+		if err := op(pi); err != nil {
+			result.Success, result.Message = false, err.Error()
+			res.Success = false
+			failed++
+		}
+		res.Results = append(res.Results, result)
+	}
+	res.Message = fmt.Sprintf("%d of %d process(es) %s", len(targets)-failed, len(targets), verb)
+	return res, nil
+}
+
+// streamMergedLogs sends the logs of every selected process, tagging each
+// line with the process it came from. Followed logs are interleaved as they
+// arrive.
+func (pms *ProcessManagerServer) streamMergedLogs(req *pb.LogRequest, stream pb.ProcessManager_StreamLogsServer) error {
+	targets, err := pms.selectProcesses(req.Name, req.Selector)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return status.Error(codes.NotFound, "no processes match")
+	}
+
+	merged := make(chan *pb.LogLine, 100)
+	followed := 0
+	for _, pi := range targets {
+		logs, err := pms.manager.Logs(pi.Name, 0)
+		if err != nil {
+			continue
+		}
+		if !req.Follow {
+			for _, line := range logs.Lines() {
+				if err := stream.Send(&pb.LogLine{Text: line, Process: pi.Name}); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		lines, channel, cancel := logs.Subscribe()
+		defer cancel()
+		for _, line := range lines {
+			if err := stream.Send(&pb.LogLine{Text: line, Process: pi.Name}); err != nil {
+				return err
+			}
+		}
+		followed++
+		go func(name string) {
+			for line := range channel {
+				select {
+				case merged <- &pb.LogLine{Text: line, Process: name}:
+				case <-stream.Context().Done():
+					return
+				}
+			}
+		}(pi.Name)
+	}
+	if followed == 0 {
+		return nil
+	}
+
+	for {
+		select {
+		case line := <-merged:
+			if err := stream.Send(line); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"regexp"
+
+	pb "github.com/brianykl/gopm/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultDescribeLogLines is the number of output lines a description
+// includes unless asked otherwise.
+const defaultDescribeLogLines = 20
+
+// secretEnv matches the names of environment variables whose values are
+// left out of descriptions.
+var secretEnv = regexp.MustCompile(`(?i)(SECRET|PASSWORD|PASSWD|TOKEN|CREDENTIAL|PRIVATE|API_?KEY|ACCESS_?KEY|AUTH)`)
+
+func (pms *ProcessManagerServer) DescribeProcess(ctx context.Context, req *pb.DescribeRequest) (*pb.ProcessDescription, error) {
+	logLines := int(req.LogLines)
+	if logLines == 0 {
+		logLines = defaultDescribeLogLines
+	}
+	d, err := pms.manager.Describe(qualify(ctx, req.Name), logLines)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	out := &pb.ProcessDescription{
+		Spec:      specToProto(d.Process.Spec),
+		Info:      pms.processInfo(&d.Process, true),
+		Healthy:   d.Process.Healthy,
+		StartedAt: timestamp(d.Process.StartedAt),
+		Logs:      d.Logs,
+	}
+	for key := range out.Spec.Env {
+		if secretEnv.MatchString(key) {
+			out.Spec.Env[key] = "<redacted>"
+		}
+	}
+	for _, exit := range d.Exits {
+		out.Exits = append(out.Exits, &pb.ExitRecord{
+			Time:   timestamp(exit.Time),
+			Reason: exit.Reason,
+			Code:   int32(exit.Code),
+			Signal: exit.Signal,
+		})
+	}
+	for _, check := range d.Health {
+		out.HealthChecks = append(out.HealthChecks, &pb.HealthCheckResult{
+			Time:    timestamp(check.Time),
+			Healthy: check.Healthy,
+			Message: check.Message,
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,341 @@
+package server
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	pb "github.com/brianykl/gopm/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+// Gateway serves the ProcessManager service as REST/JSON and the web
+// dashboard. It calls the daemon through a gRPC client rather than the
+// server directly, so requests take the same path as the CLI's.
+//
+// Requests act in the namespace given by the namespace query parameter or
+// the Gopm-Namespace header, like the CLI's current namespace.
+type Gateway struct {
+	client pb.ProcessManagerClient
+	mux    *http.ServeMux
+}
+
+func NewGateway(conn *grpc.ClientConn) *Gateway {
+	g := &Gateway{client: pb.NewProcessManagerClient(conn), mux: http.NewServeMux()}
+
+	g.mux.HandleFunc("GET /api/v1/processes", g.list)
+	g.mux.HandleFunc("POST /api/v1/processes", g.start)
+	g.mux.HandleFunc("GET /api/v1/processes/{name}", g.describe)
+	g.mux.HandleFunc("DELETE /api/v1/processes/{name}", g.remove)
+	g.mux.HandleFunc("POST /api/v1/processes/{name}/stop", g.stop)
+	g.mux.HandleFunc("POST /api/v1/processes/{name}/restart", g.restart)
+	g.mux.HandleFunc("POST /api/v1/processes/{name}/signal", g.signal)
+	g.mux.HandleFunc("POST /api/v1/processes/{name}/scale", g.scale)
+	g.mux.HandleFunc("GET /api/v1/processes/{name}/logs", g.logs)
+	g.mux.HandleFunc("GET /api/v1/events", g.events)
+
+	web, _ := fs.Sub(webFiles, "web")
+	g.mux.Handle("GET /", http.FileServer(http.FS(web)))
+	return g
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Browsers send cross-site POSTs without asking first, so any web page
+	// could start commands through a gateway without a token.
+	if r.Method != http.MethodGet && r.Method != http.MethodHead && !sameOrigin(r) {
+		replyError(w, status.Error(codes.PermissionDenied, "cross-origin requests are not allowed"))
+		return
+	}
+	g.mux.ServeHTTP(w, r)
+}
+
+// sameOrigin tells whether a request comes from a page served by the
+// gateway itself, or from something that isn't a browser.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host)
+}
+
+var gatewayJSON = protojson.MarshalOptions{EmitUnpopulated: true}
+
+// tokenCookie holds the token of the dashboard for event streams, as
+// browsers can't set headers on those.
+const tokenCookie = "gopm_token"
+
+// context passes the request's namespace and credentials on to the daemon,
+// which checks them. The token cookie only counts for GET requests, so that
+// a cross-site request can't act with it.
+func (g *Gateway) context(r *http.Request) context.Context {
+	ctx := r.Context()
+	auth := r.Header.Get("Authorization")
+	if auth == "" && r.Method == http.MethodGet {
+		if cookie, err := r.Cookie(tokenCookie); err == nil && cookie.Value != "" {
+			if token, err := url.QueryUnescape(cookie.Value); err == nil {
+				auth = "Bearer " + token
+			}
+		}
+	}
+	if auth != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", auth)
+	}
+	namespace := r.URL.Query().Get("namespace")
+	if namespace == "" {
+		namespace = r.Header.Get("Gopm-Namespace")
+	}
+	if namespace == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, NamespaceMetadataKey, namespace)
+}
+
+// decode reads the request body, if any, into req. Bodies must be JSON;
+// other types are those of forms a page on another site can post.
+func decode(r *http.Request, req proto.Message) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil || len(body) == 0 {
+		return err
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		return status.Error(codes.InvalidArgument, "request body must be application/json")
+	}
+	if err := protojson.Unmarshal(body, req); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+	}
+	return nil
+}
+
+func reply(w http.ResponseWriter, m proto.Message, err error) {
+	if err != nil {
+		replyError(w, err)
+		return
+	}
+	data, err := gatewayJSON.Marshal(m)
+	if err != nil {
+		replyError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// replyError maps a gRPC status to the closest HTTP one.
+func replyError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.OutOfRange:
+		code = http.StatusBadRequest
+	case codes.NotFound:
+		code = http.StatusNotFound
+	case codes.AlreadyExists, codes.FailedPrecondition, codes.Aborted:
+		code = http.StatusConflict
+	case codes.Unauthenticated:
+		code = http.StatusUnauthorized
+	case codes.PermissionDenied:
+		code = http.StatusForbidden
+	case codes.Unavailable:
+		code = http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		code = http.StatusGatewayTimeout
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": status.Convert(err).Message()})
+}
+
+func (g *Gateway) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := &pb.ListRequest{
+		Name:          q.Get("name"),
+		Selector:      q.Get("selector"),
+		Verbose:       q.Get("verbose") == "true",
+		AllNamespaces: q.Get("allNamespaces") == "true",
+	}
+	res, err := g.client.ListProcess(g.context(r), req)
+	reply(w, res, err)
+}
+
+func (g *Gateway) start(w http.ResponseWriter, r *http.Request) {
+	req := &pb.StartRequest{}
+	if err := decode(r, req); err != nil {
+		replyError(w, err)
+		return
+	}
+	res, err := g.client.StartProcess(g.context(r), req)
+	reply(w, res, err)
+}
+
+func (g *Gateway) describe(w http.ResponseWriter, r *http.Request) {
+	req := &pb.DescribeRequest{Name: r.PathValue("name")}
+	if lines := r.URL.Query().Get("logLines"); lines != "" {
+		n, err := strconv.Atoi(lines)
+		if err != nil {
+			replyError(w, status.Errorf(codes.InvalidArgument, "invalid logLines %q", lines))
+			return
+		}
+		req.LogLines = int32(n)
+	}
+	res, err := g.client.DescribeProcess(g.context(r), req)
+	reply(w, res, err)
+}
+
+func (g *Gateway) remove(w http.ResponseWriter, r *http.Request) {
+	req := &pb.RemoveRequest{Name: r.PathValue("name"), Selector: r.URL.Query().Get("selector")}
+	res, err := g.client.RemoveProcess(g.context(r), req)
+	reply(w, res, err)
+}
+
+func (g *Gateway) stop(w http.ResponseWriter, r *http.Request) {
+	req := &pb.StopRequest{}
+	if err := decode(r, req); err != nil {
+		replyError(w, err)
+		return
+	}
+	req.Name = r.PathValue("name")
+	res, err := g.client.StopProcess(g.context(r), req)
+	reply(w, res, err)
+}
+
+func (g *Gateway) signal(w http.ResponseWriter, r *http.Request) {
+	req := &pb.SignalRequest{}
+	if err := decode(r, req); err != nil {
+		replyError(w, err)
+		return
+	}
+	req.Name = r.PathValue("name")
+	res, err := g.client.SignalProcess(g.context(r), req)
+	reply(w, res, err)
+}
+
+func (g *Gateway) scale(w http.ResponseWriter, r *http.Request) {
+	req := &pb.ScaleRequest{}
+	if err := decode(r, req); err != nil {
+		replyError(w, err)
+		return
+	}
+	req.Name = r.PathValue("name")
+	res, err := g.client.ScaleProcess(g.context(r), req)
+	reply(w, res, err)
+}
+
+// restart waits for the whole restart, including rollouts, and replies
+// with the results of every step.
+func (g *Gateway) restart(w http.ResponseWriter, r *http.Request) {
+	req := &pb.RestartRequest{}
+	if err := decode(r, req); err != nil {
+		replyError(w, err)
+		return
+	}
+	req.Name = r.PathValue("name")
+	stream, err := g.client.RestartProcess(g.context(r), req)
+	if err != nil {
+		replyError(w, err)
+		return
+	}
+	res := &pb.ProcessResponse{Success: true}
+	for {
+		step, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			replyError(w, err)
+			return
+		}
+		res.Results = append(res.Results, step.Results...)
+		res.Success = res.Success && step.Success
+		if step.Message != "" {
+			res.Message = step.Message
+		}
+	}
+	reply(w, res, nil)
+}
+
+// logs streams log lines as server-sent events, one LogLine per event.
+// With follow=true the stream stays open.
+func (g *Gateway) logs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := &pb.LogRequest{
+		Name:     r.PathValue("name"),
+		Follow:   q.Get("follow") == "true",
+		Selector: q.Get("selector"),
+	}
+	stream, err := g.client.StreamLogs(g.context(r), req)
+	if err != nil {
+		replyError(w, err)
+		return
+	}
+	sendEvents(w, func() (proto.Message, error) { return stream.Recv() })
+}
+
+// events streams lifecycle events as server-sent events.
+func (g *Gateway) events(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := &pb.EventRequest{
+		Name:          q.Get("name"),
+		Follow:        q.Get("follow") == "true",
+		AllNamespaces: q.Get("allNamespaces") == "true",
+	}
+	stream, err := g.client.StreamEvents(g.context(r), req)
+	if err != nil {
+		replyError(w, err)
+		return
+	}
+	sendEvents(w, func() (proto.Message, error) { return stream.Recv() })
+}
+
+// sendEvents writes the messages of a stream as server-sent events. The
+// first message is awaited before the headers go out so that errors such
+// as an unknown process still get a proper status. A failure after that is
+// sent as an "error" event.
+func sendEvents(w http.ResponseWriter, recv func() (proto.Message, error)) {
+	flusher, _ := w.(http.Flusher)
+	m, err := recv()
+	if err != nil && err != io.EOF {
+		replyError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	for err == nil {
+		data, merr := gatewayJSON.Marshal(m)
+		if merr != nil {
+			err = merr
+			break
+		}
+		if _, werr := fmt.Fprintf(w, "data: %s\n\n", data); werr != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		m, err = recv()
+	}
+	if err != io.EOF && status.Code(err) != codes.Canceled {
+		data, _ := json.Marshal(map[string]string{"error": status.Convert(err).Message()})
+		fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+	}
+	// tells EventSource clients not to reconnect to a finished stream
+	fmt.Fprint(w, "event: end\ndata: {}\n\n")
+}
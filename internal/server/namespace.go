@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+
+	pm "github.com/brianykl/gopm/internal/process"
+	"google.golang.org/grpc/metadata"
+)
+
+// NamespaceMetadataKey carries the client's current namespace with every
+// call. Names in requests that aren't qualified belong to it.
+const NamespaceMetadataKey = "gopm-namespace"
+
+func namespaceFrom(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(NamespaceMetadataKey); len(values) > 0 && values[0] != "" {
+		return values[0]
+	}
+	return pm.DefaultNamespace
+}
+
+// qualify places name in the caller's namespace.
+func qualify(ctx context.Context, name string) string {
+	return pm.Qualify(namespaceFrom(ctx), name)
+}
+
+// target qualifies the name of a request that may select several
+// processes, turning "all" into every process of the caller's namespace.
+func target(ctx context.Context, name string) string {
+	if name == "" || name == "all" {
+		return namespaceFrom(ctx) + "/*"
+	}
+	return qualify(ctx, name)
+}
@@ -2,16 +2,25 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
 	"time"
 
 	pm "github.com/brianykl/gopm/internal/process"
 	pb "github.com/brianykl/gopm/proto"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 type ProcessManagerServer struct {
@@ -24,7 +33,38 @@ func NewProcessManagerServer(manager *pm.ProcessManager) *ProcessManagerServer {
 }
 
 func (pms *ProcessManagerServer) StartProcess(ctx context.Context, req *pb.StartRequest) (*pb.ProcessResponse, error) {
-	pi, err := pms.manager.StartProcess(req.Name, req.AutoRestart, req.Command, req.Args...)
+	pbSpec := req.Spec
+	if pbSpec == nil {
+		pbSpec = &pb.ProcessSpec{
+			Name:        req.Name,
+			Command:     req.Command,
+			Args:        req.Args,
+			AutoRestart: req.AutoRestart,
+		}
+	}
+
+	if pbSpec.Name == "all" && pbSpec.Command == "" {
+		if err := pms.manager.StartAll(namespaceFrom(ctx)); err != nil {
+			return &pb.ProcessResponse{
+				Success: false,
+				Message: fmt.Sprintf("failed to start processes: %v", err),
+			}, err
+		}
+		return &pb.ProcessResponse{
+			Success: true,
+			Message: "all processes started",
+		}, nil
+	}
+
+	spec, err := specFromProto(pbSpec, namespaceFrom(ctx))
+	if err != nil {
+		return &pb.ProcessResponse{
+			Success: false,
+			Message: fmt.Sprintf("invalid process spec: %v", err),
+		}, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	pi, err := pms.manager.StartProcess(spec)
 	if err != nil {
 		return &pb.ProcessResponse{
 			Success: false,
@@ -33,11 +73,31 @@ func (pms *ProcessManagerServer) StartProcess(ctx context.Context, req *pb.Start
 	}
 	return &pb.ProcessResponse{
 		Success: true,
-		Message: fmt.Sprintf("process %s started", pi.Name),
+		Message: fmt.Sprintf("process %s started", pi.Spec.Name),
 	}, nil
 }
 
 func (pms *ProcessManagerServer) StopProcess(ctx context.Context, req *pb.StopRequest) (*pb.ProcessResponse, error) {
+	if req.Name == "all" && req.Selector == "" {
+		if err := pms.manager.StopAll(namespaceFrom(ctx), req.Force); err != nil {
+			return &pb.ProcessResponse{
+				Success: false,
+				Message: fmt.Sprintf("failed to stop processes: %v", err),
+			}, nil
+		}
+		return &pb.ProcessResponse{
+			Success: true,
+			Message: "all processes stopped",
+		}, nil
+	}
+
+	req.Name = target(ctx, req.Name)
+	if isBulk(req.Name, req.Selector) {
+		return pms.bulk(req.Name, req.Selector, "stopped", func(pi *pm.ProcessInformation) error {
+			return pms.manager.StopProcess(pi, req.Force)
+		})
+	}
+
 	pi, err := pms.manager.GetProcess(req.Name)
 	if err != nil {
 		return &pb.ProcessResponse{
@@ -45,7 +105,9 @@ func (pms *ProcessManagerServer) StopProcess(ctx context.Context, req *pb.StopRe
 			Message: fmt.Sprintf("invalid process name: %v", err),
 		}, err
 	}
-	fmt.Printf("bongo pi=%+v\n", pi)
+	if pi == nil {
+		return nil, status.Errorf(codes.NotFound, "process %s not found", req.Name)
+	}
 	err = pms.manager.StopProcess(pi, req.Force)
 	if err != nil {
 		return &pb.ProcessResponse{
@@ -61,32 +123,135 @@ func (pms *ProcessManagerServer) StopProcess(ctx context.Context, req *pb.StopRe
 }
 
 func (pms *ProcessManagerServer) ListProcess(ctx context.Context, req *pb.ListRequest) (*pb.ListResponse, error) {
-	processes, err := pms.manager.ListProcesses(req.Verbose)
-	if err != nil {
-		return nil, err
+	pattern := target(ctx, req.Name)
+	if req.AllNamespaces {
+		pattern = ""
+		if req.Name != "" && req.Name != "all" {
+			pattern = pm.Qualify("*", req.Name)
+		}
+	}
+	// the processes change while they are listed, so only copies of them
+	// are read
+	processes := pms.manager.ListProcesses()
+	if pattern != "" || req.Selector != "" {
+		selected, err := pms.selectProcesses(pattern, req.Selector)
+		if err != nil {
+			return nil, err
+		}
+		names := make(map[string]bool, len(selected))
+		for _, pi := range selected {
+			names[pi.Name] = true
+		}
+		processes = slices.DeleteFunc(processes, func(pi pm.ProcessInformation) bool {
+			return !names[pi.Name]
+		})
+	}
+	// jobs and tasks have no labels, so a selector leaves them out
+	listed := func(name string) bool {
+		if req.Selector != "" {
+			return false
+		}
+		if pattern == "" {
+			return true
+		}
+		ok, _ := path.Match(pattern, name)
+		return ok
 	}
 
 	var pbProcesses []*pb.ProcessInfo
-	for _, process := range processes {
-		pbProcesses = append(pbProcesses, &pb.ProcessInfo{
-			Name:   process.Name,
-			Pid:    int32(process.PID),
-			Status: process.Status,
+	for i := range processes {
+		pbProcesses = append(pbProcesses, pms.processInfo(&processes[i], req.Verbose))
+	}
+
+	var pbJobs []*pb.JobInfo
+	for _, job := range pms.manager.ListJobs() {
+		if !listed(job.Spec.Name) {
+			continue
+		}
+		pbJobs = append(pbJobs, jobToProto(job))
+	}
+
+	var pbTasks []*pb.TaskInfo
+	for _, task := range pms.manager.ListTasks() {
+		if !listed(task.Spec.Name) {
+			continue
+		}
+		pbTasks = append(pbTasks, &pb.TaskInfo{
+			Name:    task.Spec.Name,
+			Command: task.Spec.Command,
+			Args:    task.Spec.Args,
+			Result:  runToProto(*task.Run),
 		})
 	}
 
-	return &pb.ListResponse{Processes: pbProcesses}, nil
+	return &pb.ListResponse{Processes: pbProcesses, Jobs: pbJobs, Tasks: pbTasks}, nil
+}
+
+// processInfo converts the state of a process for listings. Verbose ones
+// include resource usage, which takes a few reads from /proc and the
+// cgroup.
+func (pms *ProcessManagerServer) processInfo(process *pm.ProcessInformation, verbose bool) *pb.ProcessInfo {
+	info := &pb.ProcessInfo{
+		Name:          process.Name,
+		Pid:           int32(process.PID),
+		Status:        process.Status,
+		Labels:        process.Spec.Labels,
+		StartedAt:     timestamp(process.StartedAt),
+		Restarts:      int32(process.Restarts),
+		RestartReason: process.RestartReason,
+	}
+	if exit := process.Exit; exit != nil {
+		info.ExitReason = exit.Reason
+		info.ExitCode = int32(exit.Code)
+		info.ExitSignal = exit.Signal
+	}
+	if verbose {
+		info.CpuPercent = process.Stats.CPUPercent
+		info.MemoryBytes = process.Stats.MemoryBytes
+		info.Limits = limitsToProto(process.Spec.Limits)
+		if process.Status == "running" {
+			if sched, err := pm.EffectiveScheduling(process.PID); err == nil {
+				info.Scheduling = schedulingToProto(sched)
+			}
+		}
+		if usage, err := pms.manager.Usage(process); err == nil && usage != nil {
+			info.Usage = &pb.CgroupUsage{
+				Path:          usage.Path,
+				MemoryCurrent: usage.MemoryCurrent,
+				CpuUsageUsec:  usage.CPUUsageUsec,
+				PidsCurrent:   usage.PidsCurrent,
+			}
+		}
+	}
+	return info
 }
 
 func (pms *ProcessManagerServer) StreamLogs(req *pb.LogRequest, stream pb.ProcessManager_StreamLogsServer) error {
-	name := req.Name
-	follow := req.Follow
+	req.Name = target(stream.Context(), req.Name)
+	if isBulk(req.Name, req.Selector) {
+		return pms.streamMergedLogs(req, stream)
+	}
+	logs, err := pms.manager.Logs(req.Name, int(req.Run))
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
 
-	channel, ok := pms.manager.GetLogChannels()[name]
-	if !ok {
-		return status.Errorf(codes.NotFound, "no logs to process %s", name)
+	if !req.Follow {
+		for _, line := range logs.Lines() {
+			if err := stream.Send(&pb.LogLine{Text: line}); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
+	lines, channel, cancel := logs.Subscribe()
+	defer cancel()
+	for _, line := range lines {
+		if err := stream.Send(&pb.LogLine{Text: line}); err != nil {
+			return err
+		}
+	}
 	for {
 		select {
 		case line, open := <-channel:
@@ -98,50 +263,471 @@ func (pms *ProcessManagerServer) StreamLogs(req *pb.LogRequest, stream pb.Proces
 				return err
 			}
 
-		default:
-			if !follow {
-				return nil
-			}
-
-			time.Sleep(100 * time.Millisecond)
+		case <-stream.Context().Done():
+			return nil
 		}
 	}
-
 }
 
 func (pms *ProcessManagerServer) RemoveProcess(ctx context.Context, req *pb.RemoveRequest) (*pb.ProcessResponse, error) {
-	pi, err := pms.manager.GetProcess(req.Name)
+	req.Name = target(ctx, req.Name)
+	if req.Selector != "" {
+		return pms.bulk(req.Name, req.Selector, "removed", pms.manager.RemoveProcess)
+	}
+	if err := pms.manager.RemoveJob(req.Name); err == nil {
+		return &pb.ProcessResponse{
+			Success: true,
+			Message: fmt.Sprintf("job %s removed", req.Name),
+		}, nil
+	}
+	if err := pms.manager.RemoveTask(req.Name); err == nil {
+		return &pb.ProcessResponse{
+			Success: true,
+			Message: fmt.Sprintf("task %s removed", req.Name),
+		}, nil
+	}
+
+	res, err := pms.bulk(req.Name, req.Selector, "removed", pms.manager.RemoveProcess)
+	if status.Code(err) == codes.NotFound && !isBulk(req.Name, req.Selector) {
+		return nil, status.Errorf(codes.NotFound, "process %s not found", req.Name)
+	}
+	return res, err
+}
+
+func (pms *ProcessManagerServer) Apply(ctx context.Context, req *pb.ApplyRequest) (*pb.ProcessResponse, error) {
+	specs, jobs, err := ParseConfig(req, namespaceFrom(ctx))
 	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := pms.manager.Apply(specs); err != nil {
 		return &pb.ProcessResponse{
 			Success: false,
-			Message: fmt.Sprintf("invalid process name: %v", err),
-		}, err
+			Message: fmt.Sprintf("failed to apply config: %v", err),
+		}, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	for _, job := range jobs {
+		if err := pms.manager.AddJob(job, true); err != nil {
+			return &pb.ProcessResponse{
+				Success: false,
+				Message: fmt.Sprintf("failed to apply config: %v", err),
+			}, status.Error(codes.FailedPrecondition, err.Error())
+		}
 	}
-	fmt.Printf("pi=%+v\n", pi)
+	return &pb.ProcessResponse{
+		Success: true,
+		Message: fmt.Sprintf("applied %d process(es) and %d job(s)", len(specs), len(jobs)),
+	}, nil
+}
 
-	if pi.Status == "running" {
+func (pms *ProcessManagerServer) ScaleProcess(ctx context.Context, req *pb.ScaleRequest) (*pb.ProcessResponse, error) {
+	req.Name = qualify(ctx, req.Name)
+	if err := pms.manager.Scale(req.Name, int(req.Instances)); err != nil {
+		return &pb.ProcessResponse{
+			Success: false,
+			Message: fmt.Sprintf("failed to scale process: %v", err),
+		}, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &pb.ProcessResponse{
+		Success: true,
+		Message: fmt.Sprintf("process %s scaled to %d instance(s)", req.Name, req.Instances),
+	}, nil
+}
+
+func (pms *ProcessManagerServer) RestartProcess(req *pb.RestartRequest, stream pb.ProcessManager_RestartProcessServer) error {
+	req.Name = target(stream.Context(), req.Name)
+	var targets []*pm.ProcessInformation
+	if isBulk(req.Name, req.Selector) {
+		var err error
+		if targets, err = pms.selectProcesses(req.Name, req.Selector); err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			return status.Error(codes.NotFound, "no processes match")
+		}
+	}
 
+	if !req.Rolling && targets != nil {
+		failed := 0
+		for _, pi := range targets {
+			result := &pb.ProcessResult{Name: pi.Name, Success: true, Message: "restarted"}
+			if err := pms.manager.Restart(pi.Name, req.Force, pm.ExitReasonRestarted, "restart requested"); err != nil {
+				result.Success, result.Message = false, err.Error()
+				failed++
+			}
+			res := &pb.ProcessResponse{Success: result.Success, Results: []*pb.ProcessResult{result}}
+			if err := stream.Send(res); err != nil {
+				return err
+			}
+		}
+		return stream.Send(&pb.ProcessResponse{
+			Success: failed == 0,
+			Message: fmt.Sprintf("%d of %d process(es) restarted", len(targets)-failed, len(targets)),
+		})
+	}
+	if !req.Rolling {
+		if err := pms.manager.Restart(req.Name, req.Force, pm.ExitReasonRestarted, "restart requested"); err != nil {
+			return status.Errorf(codes.NotFound, "failed to restart process: %v", err)
+		}
+		return stream.Send(&pb.ProcessResponse{
+			Success: true,
+			Message: fmt.Sprintf("process %s restarted", req.Name),
+		})
+	}
+
+	minUptime, err := parseDuration(req.MinUptime)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid min uptime: %v", err)
+	}
+	readyTimeout, err := parseDuration(req.ReadyTimeout)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid ready timeout: %v", err)
+	}
+
+	opts := pm.RolloutOptions{
+		BatchSize:    int(req.BatchSize),
+		MinUptime:    minUptime,
+		ReadyTimeout: readyTimeout,
+	}
+	progress := func(line string) {
+		stream.Send(&pb.ProcessResponse{Success: true, Message: line})
+	}
+	if targets != nil {
+		err = pms.manager.RollingRestartProcesses(req.Name, targets, opts, progress)
 	} else {
-		err = pms.manager.RemoveProcess(pi)
+		err = pms.manager.RollingRestart(req.Name, opts, progress)
+	}
+	if err != nil {
+		return status.Error(codes.Aborted, err.Error())
 	}
+	return stream.Send(&pb.ProcessResponse{
+		Success: true,
+		Message: fmt.Sprintf("rolling restart of %s complete", req.Name),
+	})
+}
 
+func (pms *ProcessManagerServer) ScheduleJob(ctx context.Context, req *pb.JobSpec) (*pb.ProcessResponse, error) {
+	job, err := jobFromProto(req, namespaceFrom(ctx))
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid job: %v", err)
+	}
+	if err := pms.manager.AddJob(job, false); err != nil {
+		return &pb.ProcessResponse{
+			Success: false,
+			Message: fmt.Sprintf("failed to schedule job: %v", err),
+		}, status.Error(codes.InvalidArgument, err.Error())
+	}
 	return &pb.ProcessResponse{
 		Success: true,
-		Message: fmt.Sprintf("process %s removed", pi.Name),
+		Message: fmt.Sprintf("job %s scheduled", job.Name),
 	}, nil
 }
 
-func StartServer() {
+func (pms *ProcessManagerServer) StreamEvents(req *pb.EventRequest, stream pb.ProcessManager_StreamEventsServer) error {
+	events, channel, cancel := pms.manager.Events().Subscribe()
+	defer cancel()
+
+	namespace := namespaceFrom(stream.Context())
+	name := qualify(stream.Context(), req.Name)
+	send := func(e pm.Event) error {
+		if name != "" && e.Process != name && !strings.HasPrefix(e.Process, name+":") {
+			return nil
+		}
+		if name == "" && !req.AllNamespaces && pm.Namespace(e.Process) != namespace {
+			return nil
+		}
+		return stream.Send(&pb.Event{
+			Time:    timestamp(e.Time),
+			Process: e.Process,
+			Type:    e.Type,
+			Message: e.Message,
+		})
+	}
+
+	for _, e := range events {
+		if err := send(e); err != nil {
+			return err
+		}
+	}
+	if !req.Follow {
+		return nil
+	}
+	for {
+		select {
+		case e, open := <-channel:
+			if !open {
+				return nil
+			}
+			if err := send(e); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (pms *ProcessManagerServer) SignalProcess(ctx context.Context, req *pb.SignalRequest) (*pb.ProcessResponse, error) {
+	sig, err := pm.ParseSignal(req.Signal)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	req.Name = target(ctx, req.Name)
+	if isBulk(req.Name, req.Selector) {
+		return pms.bulk(req.Name, req.Selector, "signalled", func(pi *pm.ProcessInformation) error {
+			_, err := pms.manager.Signal(pi.Name, sig, req.Group)
+			return err
+		})
+	}
+	delivered, err := pms.manager.Signal(req.Name, sig, req.Group)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &pb.ProcessResponse{
+		Message: strings.Join(delivered, "\n"),
+		Success: true,
+	}, nil
+}
+
+func (pms *ProcessManagerServer) WriteStdin(stream pb.ProcessManager_WriteStdinServer) error {
+	var name string
+	var written int
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			if name = req.Name; name == "" {
+				return status.Error(codes.InvalidArgument, "missing process name")
+			}
+			name = qualify(stream.Context(), name)
+		}
+		if len(req.Data) > 0 {
+			if err := pms.manager.WriteStdin(name, req.Data); err != nil {
+				return status.Error(codes.FailedPrecondition, err.Error())
+			}
+			written += len(req.Data)
+		}
+		if req.Close {
+			if err := pms.manager.CloseStdin(name); err != nil {
+				return status.Error(codes.FailedPrecondition, err.Error())
+			}
+		}
+	}
+	return stream.SendAndClose(&pb.ProcessResponse{
+		Message: fmt.Sprintf("sent %d bytes to %s", written, name),
+		Success: true,
+	})
+}
+
+func (pms *ProcessManagerServer) Attach(stream pb.ProcessManager_AttachServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	attachment, err := pms.manager.Attach(qualify(stream.Context(), first.Name))
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	defer attachment.Detach()
+
+	input := func(req *pb.AttachRequest) {
+		if req.Rows > 0 && req.Cols > 0 {
+			attachment.Resize(uint16(req.Rows), uint16(req.Cols))
+		}
+		if len(req.Input) > 0 {
+			attachment.Write(req.Input)
+		}
+	}
+	input(first)
+	// the client detaches by closing its side of the stream
+	go func() {
+		defer attachment.Detach()
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			input(req)
+		}
+	}()
+
+	// sent even when empty, as clients wait for it to know the attach
+	// succeeded
+	if err := stream.Send(&pb.AttachOutput{Data: attachment.Scrollback}); err != nil {
+		return err
+	}
+	for data := range attachment.Output {
+		if err := stream.Send(&pb.AttachOutput{Data: data}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultTaskTTL is how long finished task results are kept when the
+// request doesn't say.
+const defaultTaskTTL = 24 * time.Hour
+
+func (pms *ProcessManagerServer) RunTask(req *pb.RunTaskRequest, stream pb.ProcessManager_RunTaskServer) error {
+	req.Name = qualify(stream.Context(), req.Name)
+	if req.Name != "" {
+		if err := pm.ValidateName(req.Name); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	timeout, err := parseDuration(req.Timeout)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid timeout: %v", err)
+	}
+	ttl := defaultTaskTTL
+	if req.Ttl != "" {
+		if ttl, err = time.ParseDuration(req.Ttl); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid ttl: %v", err)
+		}
+	}
+
+	spec := pm.JobSpec{
+		Name:    req.Name,
+		Command: req.Command,
+		Args:    req.Args,
+		Env:     req.Env,
+		Timeout: timeout,
+	}
+	task, err := pms.manager.RunTask(spec, ttl)
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if !req.Wait {
+		return stream.Send(&pb.TaskOutput{Text: fmt.Sprintf("task %s started", req.Name)})
+	}
+
+	lines, channel, cancel := task.Run.Logs.Subscribe()
+	defer cancel()
+	for _, line := range lines {
+		if err := stream.Send(&pb.TaskOutput{Text: line}); err != nil {
+			return err
+		}
+	}
+	for open := true; open; {
+		var line string
+		select {
+		case line, open = <-channel:
+			if open {
+				if err := stream.Send(&pb.TaskOutput{Text: line}); err != nil {
+					return err
+				}
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+
+	result, err := pms.manager.TaskResult(req.Name)
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return stream.Send(&pb.TaskOutput{Result: runToProto(result)})
+}
+
+// Options configure the daemon.
+type Options struct {
+	// Address is the TCP address or unix:/path socket to listen on,
+	// :50051 by default.
+	Address string
+	// TLSCert and TLSKey, if set, make the daemon serve TLS. With
+	// TLSClientCA clients must present a certificate signed by it.
+	TLSCert, TLSKey, TLSClientCA string
+	// Token, if set, must be sent by clients as a bearer token.
+	Token string
+	// HTTPAddr, if set, is where the REST gateway and web dashboard are
+	// served.
+	HTTPAddr string
+	// StateFile, if set, is where the processes and jobs are saved
+	// whenever they change. With Resurrect they are started from it when
+	// the daemon starts.
+	StateFile string
+	Resurrect bool
+}
+
+func StartServer(opts Options) {
+	if opts.Address == "" {
+		opts.Address = ":50051"
+	}
 	manager := pm.NewProcessManager()
-	grpcServer := grpc.NewServer()
-	pb.RegisterProcessManagerServer(grpcServer, NewProcessManagerServer(manager))
+	service := NewProcessManagerServer(manager)
 
-	lis, err := net.Listen("tcp", ":50051")
+	var serverOpts []grpc.ServerOption
+	var tlsConfig *tls.Config
+	if opts.TLSCert != "" || opts.TLSKey != "" {
+		var err error
+		if tlsConfig, err = serverTLSConfig(opts); err != nil {
+			log.Fatal(err)
+		}
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	var interceptors []grpc.ServerOption
+	if opts.Token != "" {
+		interceptors = append(interceptors, tokenInterceptors(opts.Token)...)
+	}
+	if opts.StateFile != "" {
+		state := &stateFile{path: opts.StateFile, manager: manager}
+		if opts.Resurrect {
+			if err := state.resurrect(); err != nil {
+				fmt.Printf("failed to resurrect processes: %v\n", err)
+			}
+		}
+		interceptors = append(interceptors, state.interceptors()...)
+	}
+	serverOpts = append(serverOpts, interceptors...)
+	grpcServer := grpc.NewServer(serverOpts...)
+	pb.RegisterProcessManagerServer(grpcServer, service)
+
+	lis, err := listen(opts.Address)
 	if err != nil {
-		log.Fatalf("failed to listen on port 50051: %v", err)
+		log.Fatalf("failed to listen on %s: %v", opts.Address, err)
 	}
 
-	fmt.Println("process manager daemon listening on 50051...")
+	fmt.Printf("process manager daemon listening on %s...\n", opts.Address)
+
+	if opts.HTTPAddr != "" {
+		// The gateway reaches the service through an in-memory connection,
+		// which needs the token (forwarded from the HTTP request) and saves
+		// the state like the daemon's but doesn't need its TLS.
+		internal := bufconn.Listen(1 << 20)
+		internalServer := grpc.NewServer(interceptors...)
+		pb.RegisterProcessManagerServer(internalServer, service)
+		go internalServer.Serve(internal)
+
+		conn, err := grpc.NewClient("passthrough:///gateway",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return internal.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			log.Fatalf("failed to connect the gateway: %v", err)
+		}
+		httpLis, err := net.Listen("tcp", opts.HTTPAddr)
+		if err != nil {
+			log.Fatalf("failed to listen on %s: %v", opts.HTTPAddr, err)
+		}
+		// the dashboard sends the token, so it gets the daemon's TLS too
+		scheme := "http"
+		if tlsConfig != nil {
+			httpLis = tls.NewListener(httpLis, tlsConfig.Clone())
+			scheme = "https"
+		}
+		fmt.Printf("web dashboard and REST gateway listening on %s://%s...\n", scheme, opts.HTTPAddr)
+		go func() {
+			if err := http.Serve(httpLis, NewGateway(conn)); err != nil {
+				log.Fatalf("failed to serve http: %v", err)
+			}
+		}()
+	}
 
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
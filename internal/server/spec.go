@@ -0,0 +1,287 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	pm "github.com/brianykl/gopm/internal/process"
+	pb "github.com/brianykl/gopm/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// specFromProto converts a spec, placing its name and dependencies in
+// namespace unless they are qualified.
+func specFromProto(in *pb.ProcessSpec, namespace string) (pm.ProcessSpec, error) {
+	if in.Name == "" {
+		return pm.ProcessSpec{}, fmt.Errorf("missing process name")
+	}
+	name := pm.Qualify(namespace, in.Name)
+	if err := pm.ValidateName(name); err != nil {
+		return pm.ProcessSpec{}, err
+	}
+	if in.Command == "" {
+		return pm.ProcessSpec{}, fmt.Errorf("missing command")
+	}
+
+	spec := pm.ProcessSpec{
+		Name:        name,
+		Command:     in.Command,
+		Args:        in.Args,
+		Env:         in.Env,
+		Cwd:         in.Cwd,
+		AutoRestart: in.AutoRestart,
+		Instances:   int(in.Instances),
+		BasePort:    int(in.BasePort),
+		MaxMemory:   in.MaxMemory,
+		MaxCPU:      in.MaxCpu,
+		TTY:         in.Tty,
+		Stdin:       in.Stdin,
+		Labels:      in.Labels,
+	}
+	threshold, err := parseDuration(in.ThresholdDuration)
+	if err != nil {
+		return pm.ProcessSpec{}, fmt.Errorf("threshold duration: %v", err)
+	}
+	spec.ThresholdDuration = threshold
+	for _, dep := range in.DependsOn {
+		spec.DependsOn = append(spec.DependsOn, pm.Dependency{
+			Name:      pm.Qualify(namespace, dep.Name),
+			Condition: dep.Condition,
+		})
+	}
+
+	if hc := in.HealthCheck; hc != nil && hc.Command != "" {
+		interval, err := parseDuration(hc.Interval)
+		if err != nil {
+			return pm.ProcessSpec{}, fmt.Errorf("health check interval: %v", err)
+		}
+		timeout, err := parseDuration(hc.Timeout)
+		if err != nil {
+			return pm.ProcessSpec{}, fmt.Errorf("health check timeout: %v", err)
+		}
+		spec.HealthCheck = &pm.HealthCheck{
+			Command:  hc.Command,
+			Interval: interval,
+			Timeout:  timeout,
+			Retries:  int(hc.Retries),
+		}
+	}
+	if l := in.Limits; l != nil {
+		spec.Limits = &pm.ResourceLimits{
+			NoFile:       l.NoFile,
+			Core:         l.Core,
+			AddressSpace: l.AddressSpace,
+			MemoryMax:    l.MemoryMax,
+			CPUs:         l.Cpus,
+			PidsMax:      l.PidsMax,
+			IOWeight:     int(l.IoWeight),
+		}
+	}
+
+	if sc := in.Scheduling; sc != nil {
+		spec.Scheduling = &pm.Scheduling{
+			IOClass: sc.IoClass,
+			IOLevel: int(sc.IoLevel),
+		}
+		if sc.Nice != nil {
+			nice := int(*sc.Nice)
+			spec.Scheduling.Nice = &nice
+		}
+		for _, cpu := range sc.Cpus {
+			spec.Scheduling.CPUs = append(spec.Scheduling.CPUs, int(cpu))
+		}
+		if err := spec.Scheduling.Validate(); err != nil {
+			return pm.ProcessSpec{}, err
+		}
+	}
+
+	if w := in.Watch; w != nil && len(w.Paths) > 0 {
+		debounce, err := parseDuration(w.Debounce)
+		if err != nil {
+			return pm.ProcessSpec{}, fmt.Errorf("watch debounce: %v", err)
+		}
+		spec.Watch = &pm.WatchSpec{
+			Paths:    w.Paths,
+			Ignore:   w.Ignore,
+			Debounce: debounce,
+		}
+	}
+	return spec, nil
+}
+
+// specToProto converts a spec back, for display and the state file. Env is
+// copied, so callers may redact it.
+func specToProto(spec pm.ProcessSpec) *pb.ProcessSpec {
+	out := &pb.ProcessSpec{
+		Name:        spec.Name,
+		Command:     spec.Command,
+		Args:        spec.Args,
+		Cwd:         spec.Cwd,
+		AutoRestart: spec.AutoRestart,
+		Instances:   int32(spec.Instances),
+		BasePort:    int32(spec.BasePort),
+		Limits:      limitsToProto(spec.Limits),
+		Scheduling:  schedulingToProto(spec.Scheduling),
+		MaxMemory:   spec.MaxMemory,
+		MaxCpu:      spec.MaxCPU,
+		Tty:         spec.TTY,
+		Stdin:       spec.Stdin,
+		Labels:      spec.Labels,
+	}
+	if len(spec.Env) > 0 {
+		out.Env = make(map[string]string, len(spec.Env))
+		for key, value := range spec.Env {
+			out.Env[key] = value
+		}
+	}
+	if spec.ThresholdDuration > 0 {
+		out.ThresholdDuration = spec.ThresholdDuration.String()
+	}
+	for _, dep := range spec.DependsOn {
+		out.DependsOn = append(out.DependsOn, &pb.Dependency{Name: dep.Name, Condition: dep.Condition})
+	}
+	if hc := spec.HealthCheck; hc != nil {
+		out.HealthCheck = &pb.HealthCheck{Command: hc.Command, Retries: int32(hc.Retries)}
+		if hc.Interval > 0 {
+			out.HealthCheck.Interval = hc.Interval.String()
+		}
+		if hc.Timeout > 0 {
+			out.HealthCheck.Timeout = hc.Timeout.String()
+		}
+	}
+	if w := spec.Watch; w != nil {
+		out.Watch = &pb.WatchSpec{Paths: w.Paths, Ignore: w.Ignore}
+		if w.Debounce > 0 {
+			out.Watch.Debounce = w.Debounce.String()
+		}
+	}
+	return out
+}
+
+// ParseConfig converts the processes and jobs of a config file, placing
+// names that aren't qualified in namespace.
+func ParseConfig(req *pb.ApplyRequest, namespace string) ([]pm.ProcessSpec, []pm.JobSpec, error) {
+	specs := make([]pm.ProcessSpec, 0, len(req.Processes))
+	for _, pbSpec := range req.Processes {
+		spec, err := specFromProto(pbSpec, namespace)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid spec for %q: %v", pbSpec.Name, err)
+		}
+		specs = append(specs, spec)
+	}
+
+	jobs := make([]pm.JobSpec, 0, len(req.Jobs))
+	for _, pbJob := range req.Jobs {
+		job, err := jobFromProto(pbJob, namespace)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid job %q: %v", pbJob.Name, err)
+		}
+		jobs = append(jobs, job)
+	}
+	return specs, jobs, nil
+}
+
+// parseDuration treats an empty string as zero so callers can fall back to
+// their defaults.
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func limitsToProto(l *pm.ResourceLimits) *pb.ResourceLimits {
+	if l == nil {
+		return nil
+	}
+	return &pb.ResourceLimits{
+		NoFile:       l.NoFile,
+		Core:         l.Core,
+		AddressSpace: l.AddressSpace,
+		MemoryMax:    l.MemoryMax,
+		Cpus:         l.CPUs,
+		PidsMax:      l.PidsMax,
+		IoWeight:     int32(l.IOWeight),
+	}
+}
+
+func schedulingToProto(s *pm.Scheduling) *pb.Scheduling {
+	if s == nil {
+		return nil
+	}
+	out := &pb.Scheduling{IoClass: s.IOClass, IoLevel: int32(s.IOLevel)}
+	if s.Nice != nil {
+		nice := int32(*s.Nice)
+		out.Nice = &nice
+	}
+	for _, cpu := range s.CPUs {
+		out.Cpus = append(out.Cpus, int32(cpu))
+	}
+	return out
+}
+
+func jobFromProto(in *pb.JobSpec, namespace string) (pm.JobSpec, error) {
+	timeout, err := parseDuration(in.Timeout)
+	if err != nil {
+		return pm.JobSpec{}, fmt.Errorf("timeout: %v", err)
+	}
+	name := pm.Qualify(namespace, in.Name)
+	if err := pm.ValidateName(name); err != nil {
+		return pm.JobSpec{}, err
+	}
+	return pm.JobSpec{
+		Name:              name,
+		Command:           in.Command,
+		Args:              in.Args,
+		Env:               in.Env,
+		Schedule:          in.Schedule,
+		ConcurrencyPolicy: in.ConcurrencyPolicy,
+		Timeout:           timeout,
+		HistoryLimit:      int(in.HistoryLimit),
+	}, nil
+}
+
+func jobSpecToProto(spec pm.JobSpec) *pb.JobSpec {
+	out := &pb.JobSpec{
+		Name:              spec.Name,
+		Command:           spec.Command,
+		Args:              spec.Args,
+		Env:               spec.Env,
+		Schedule:          spec.Schedule,
+		ConcurrencyPolicy: spec.ConcurrencyPolicy,
+		HistoryLimit:      int32(spec.HistoryLimit),
+	}
+	if spec.Timeout > 0 {
+		out.Timeout = spec.Timeout.String()
+	}
+	return out
+}
+
+func jobToProto(job pm.Job) *pb.JobInfo {
+	info := &pb.JobInfo{
+		Spec:    jobSpecToProto(job.Spec),
+		NextRun: timestamp(job.NextRun),
+	}
+	for _, run := range job.Runs {
+		info.Runs = append(info.Runs, runToProto(*run))
+	}
+	return info
+}
+
+func runToProto(run pm.JobRun) *pb.JobRun {
+	return &pb.JobRun{
+		Id:         int32(run.ID),
+		Status:     run.Status,
+		ExitCode:   int32(run.ExitCode),
+		StartedAt:  timestamp(run.StartedAt),
+		FinishedAt: timestamp(run.FinishedAt),
+	}
+}
+
+// timestamp leaves unset times unset rather than encoding the zero time.
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
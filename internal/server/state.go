@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	pm "github.com/brianykl/gopm/internal/process"
+	pb "github.com/brianykl/gopm/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// StatePath returns where the daemon of the user whose home is home keeps
+// its state: $XDG_STATE_HOME/gopm/state.json when home is the current
+// user's and XDG_STATE_HOME is set, else ~/.local/state/gopm/state.json.
+func StatePath(home string) string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		if own, _ := os.UserHomeDir(); own == home {
+			return filepath.Join(dir, "gopm", "state.json")
+		}
+	}
+	return filepath.Join(home, ".local", "state", "gopm", "state.json")
+}
+
+// stateChanges are the calls after which the state is saved.
+var stateChanges = map[string]bool{
+	pb.ProcessManager_StartProcess_FullMethodName:   true,
+	pb.ProcessManager_StopProcess_FullMethodName:    true,
+	pb.ProcessManager_RemoveProcess_FullMethodName:  true,
+	pb.ProcessManager_Apply_FullMethodName:          true,
+	pb.ProcessManager_ScaleProcess_FullMethodName:   true,
+	pb.ProcessManager_RestartProcess_FullMethodName: true,
+	pb.ProcessManager_ScheduleJob_FullMethodName:    true,
+}
+
+// stateFile keeps the processes and jobs of the daemon in a file, in the
+// format of `gopm apply` config files, so that they can be brought back
+// after a reboot. It is written after every call that changes them.
+type stateFile struct {
+	path    string
+	manager *pm.ProcessManager
+	mu      sync.Mutex
+}
+
+func (s *stateFile) save() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	specs, jobs := s.manager.Snapshot()
+	state := &pb.ApplyRequest{}
+	for _, spec := range specs {
+		state.Processes = append(state.Processes, specToProto(spec))
+	}
+	for _, job := range jobs {
+		state.Jobs = append(state.Jobs, jobSpecToProto(job))
+	}
+	data, err := protojson.MarshalOptions{Multiline: true}.Marshal(state)
+	if err == nil {
+		err = writeFileAtomic(s.path, data)
+	}
+	if err != nil {
+		fmt.Printf("failed to save state to %s: %v\n", s.path, err)
+	}
+}
+
+// writeFileAtomic replaces path with data, readable only by the owner as
+// the environment of processes may hold secrets.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".state-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// resurrect starts the processes and jobs of the state file, if there is
+// one.
+func (s *stateFile) resurrect() error {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	state := &pb.ApplyRequest{}
+	if err := protojson.Unmarshal(data, state); err != nil {
+		return fmt.Errorf("%s: %v", s.path, err)
+	}
+	// names in the state file are qualified
+	specs, jobs, err := ParseConfig(state, pm.DefaultNamespace)
+	if err != nil {
+		return fmt.Errorf("%s: %v", s.path, err)
+	}
+	if err := s.manager.Apply(specs); err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if err := s.manager.AddJob(job, true); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("resurrected %d process(es) and %d job(s) from %s\n", len(specs), len(jobs), s.path)
+	return nil
+}
+
+// interceptors save the state after the calls that change it.
+func (s *stateFile) interceptors() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler) (any, error) {
+			res, err := handler(ctx, req)
+			if stateChanges[info.FullMethod] {
+				s.save()
+			}
+			return res, err
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo,
+			handler grpc.StreamHandler) error {
+			err := handler(srv, stream)
+			if stateChanges[info.FullMethod] {
+				s.save()
+			}
+			return err
+		}),
+	}
+}
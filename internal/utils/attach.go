@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	pb "github.com/brianykl/gopm/proto"
+	"golang.org/x/term"
+)
+
+const defaultDetachKeys = "ctrl-p,ctrl-q"
+
+// attachFlags returns the flag set of attach, which sets detachKeys.
+func attachFlags(detachKeys *string) *flag.FlagSet {
+	fs := commandFlagSet("attach")
+	fs.StringVar(detachKeys, "detach-keys", defaultDetachKeys, "key sequence that detaches, e.g. ctrl-p,ctrl-q")
+	return fs
+}
+
+func RunAttach(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
+	var detachKeys string
+	fs := attachFlags(&detachKeys)
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return UsageError{"gopm attach <flag> <name>"}
+	}
+	name := fs.Arg(0)
+	keys, err := parseDetachKeys(detachKeys)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.Attach(ctx)
+	if err != nil {
+		return err
+	}
+	// input and resizes are sent from different goroutines
+	var sendMu sync.Mutex
+	send := func(req *pb.AttachRequest) error {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		return stream.Send(req)
+	}
+
+	stdin := int(os.Stdin.Fd())
+	first := &pb.AttachRequest{Name: name}
+	if cols, rows, err := term.GetSize(stdin); err == nil {
+		first.Rows, first.Cols = uint32(rows), uint32(cols)
+	}
+	if err := send(first); err != nil {
+		return err
+	}
+	// fail before touching the terminal if the daemon refuses
+	out, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	if term.IsTerminal(stdin) {
+		state, err := term.MakeRaw(stdin)
+		if err != nil {
+			return err
+		}
+		defer term.Restore(stdin, state)
+	}
+	fmt.Fprintf(os.Stderr, "attached to %s, detach with %s\r\n", name, detachKeys)
+
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+	go func() {
+		for range winch {
+			if cols, rows, err := term.GetSize(stdin); err == nil {
+				send(&pb.AttachRequest{Rows: uint32(rows), Cols: uint32(cols)})
+			}
+		}
+	}()
+
+	detached := make(chan struct{})
+	go func() {
+		matcher := &keyMatcher{keys: keys}
+		buf := make([]byte, 1024)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if n > 0 {
+				input, detach := matcher.feed(buf[:n])
+				if len(input) > 0 {
+					send(&pb.AttachRequest{Input: input})
+				}
+				if detach {
+					close(detached)
+					sendMu.Lock()
+					stream.CloseSend()
+					sendMu.Unlock()
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		os.Stdout.Write(out.Data)
+		out, err = stream.Recv()
+		if err != nil {
+			select {
+			case <-detached:
+				fmt.Fprintf(os.Stderr, "\r\ndetached from %s\r\n", name)
+				return nil
+			default:
+			}
+			if err == io.EOF {
+				fmt.Fprintf(os.Stderr, "\r\n%s exited\r\n", name)
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// parseDetachKeys turns a list like "ctrl-p,ctrl-q" into the bytes the
+// terminal sends for it.
+func parseDetachKeys(s string) ([]byte, error) {
+	var keys []byte
+	for _, key := range strings.Split(s, ",") {
+		if c, ok := strings.CutPrefix(key, "ctrl-"); ok && len(c) == 1 {
+			keys = append(keys, c[0]&0x1f)
+		} else if len(key) == 1 {
+			keys = append(keys, key[0])
+		} else {
+			return nil, fmt.Errorf("invalid detach key %q", key)
+		}
+	}
+	return keys, nil
+}
+
+// keyMatcher finds the detach sequence in the input, holding back a
+// partial match until it knows whether the sequence completes.
+type keyMatcher struct {
+	keys    []byte
+	pending []byte
+}
+
+func (m *keyMatcher) feed(data []byte) ([]byte, bool) {
+	var out []byte
+	for _, b := range data {
+		if b == m.keys[len(m.pending)] {
+			m.pending = append(m.pending, b)
+			if len(m.pending) == len(m.keys) {
+				return out, true
+			}
+			continue
+		}
+		out = append(out, m.pending...)
+		m.pending = m.pending[:0]
+		if b == m.keys[0] {
+			m.pending = append(m.pending, b)
+		} else {
+			out = append(out, b)
+		}
+	}
+	return out, false
+}
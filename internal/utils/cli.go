@@ -7,35 +7,91 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/brianykl/gopm/internal/process"
 	"github.com/brianykl/gopm/internal/server"
 	pb "github.com/brianykl/gopm/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 )
 
-func Usage() {
-	fmt.Println("usage: client <start|stop|list> ...")
+// parseServerFlags parses the flags of init and init-bg.
+func parseServerFlags(name string, args []string) (server.Options, error) {
+	fs := commandFlagSet(name)
+	opts, check := serverFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return *opts, err
+	}
+	if fs.NArg() > 0 {
+		return *opts, UsageError{"gopm " + name + " <flag>"}
+	}
+	return *opts, check()
+}
+
+// initFlags returns the Flags of init or init-bg.
+func initFlags(name string) func() *flag.FlagSet {
+	return func() *flag.FlagSet {
+		fs := commandFlagSet(name)
+		serverFlags(fs)
+		return fs
+	}
+}
+
+// serverFlags registers the flags of the daemon on fs. The returned
+// function checks them and reads the token once fs is parsed.
+func serverFlags(fs *flag.FlagSet) (*server.Options, func() error) {
+	opts := &server.Options{}
+	var tokenFile string
+	fs.StringVar(&opts.Address, "listen", ":50051", "TCP address or unix:/path socket to serve gRPC on")
+	fs.StringVar(&opts.TLSCert, "tls-cert", "", "serve TLS with this certificate (PEM)")
+	fs.StringVar(&opts.TLSKey, "tls-key", "", "private key of --tls-cert (PEM)")
+	fs.StringVar(&opts.TLSClientCA, "tls-client-ca", "", "require client certificates signed by this CA (PEM)")
+	fs.StringVar(&tokenFile, "token-file", "", "require clients to send the token in this file (default: $GOPM_TOKEN)")
+	fs.StringVar(&opts.HTTPAddr, "http", "", "also serve the REST gateway and web dashboard on this address, e.g. localhost:8080")
+	fs.StringVar(&opts.StateFile, "state", "", "save processes and jobs to this file whenever they change (default: none)")
+	fs.BoolVar(&opts.Resurrect, "resurrect", false, "start the processes and jobs saved in --state")
+	return opts, func() error {
+		if (opts.TLSCert == "") != (opts.TLSKey == "") {
+			return fmt.Errorf("--tls-cert and --tls-key go together")
+		}
+		if opts.TLSClientCA != "" && opts.TLSCert == "" {
+			return fmt.Errorf("--tls-client-ca needs --tls-cert")
+		}
+		if opts.Resurrect && opts.StateFile == "" {
+			return fmt.Errorf("--resurrect needs --state")
+		}
+		opts.Token = os.Getenv("GOPM_TOKEN")
+		if tokenFile != "" {
+			data, err := os.ReadFile(tokenFile)
+			if err != nil {
+				return fmt.Errorf("failed to read token: %v", err)
+			}
+			opts.Token = strings.TrimSpace(string(data))
+		}
+		return nil
+	}
 }
 
 func RunServer(args []string) error {
-	// fs := flag.NewFlagSet("bg", flag.ContinueOnError)
-	// var runInBg bool
-	// fs.BoolVar(&runInBg, "background", false, "run server in background")
-	// err := fs.Parse(args)
-	// if err != nil {
-	// 	return err
-	// }
-
-	// subcommand := fs.Args()
-	// if len(subcommand) > 1 {
-	// 	return fmt.Errorf("usage: gopm init <flag>")
-	// }
-
-	server.StartServer()
+	opts, err := parseServerFlags("init", args)
+	if err != nil {
+		return err
+	}
+
+	server.StartServer(opts)
 	return nil
 }
 
 func RunServerInBackground(args []string) error {
-	cmd := exec.Command(os.Args[0], "init")
+	if _, err := parseServerFlags("init-bg", args); err != nil {
+		return err
+	}
+	cmd := exec.Command(os.Args[0], append([]string{"init"}, args...)...)
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start server in background: %v", err)
 	}
@@ -43,116 +99,800 @@ func RunServerInBackground(args []string) error {
 	return nil
 }
 
+// startOptions are the flags of start.
+type startOptions struct {
+	autoRestart, dependsOn    string
+	healthCmd, healthInterval string
+
+	instances, basePort int
+	cwd                 string
+
+	watch, ignore []string
+	debounce      string
+
+	limits     *pb.ResourceLimits
+	scheduling *pb.Scheduling
+	labels     map[string]string
+	env        map[string]string
+	tty, stdin bool
+
+	maxMemory    uint64
+	maxCPU       float64
+	thresholdFor string
+}
+
+func startFlags(o *startOptions) *flag.FlagSet {
+	fs := newFlagSet("start")
+	fs.StringVar(&o.autoRestart, "auto-restart", "never", "auto restart policy (never|always|on-failure)")
+	fs.StringVar(&o.dependsOn, "depends-on", "", "comma separated dependencies as name[:started|healthy]")
+	fs.StringVar(&o.healthCmd, "health-cmd", "", "shell command whose success marks the process healthy")
+	fs.StringVar(&o.healthInterval, "health-interval", "", "interval between health checks (e.g. 5s)")
+	fs.IntVar(&o.instances, "instances", 0, "number of replicas to run, named <name>:<index>")
+	fs.IntVar(&o.basePort, "port", 0, "base port; each replica gets PORT=port+index")
+	fs.StringVar(&o.cwd, "cwd", "", "working directory of the process (default: the current directory)")
+	fs.Func("watch", "restart when files under this path change (repeatable)", func(s string) error {
+		o.watch = append(o.watch, s)
+		return nil
+	})
+	fs.Func("ignore", "glob of paths to ignore while watching (repeatable)", func(s string) error {
+		o.ignore = append(o.ignore, s)
+		return nil
+	})
+	fs.StringVar(&o.debounce, "watch-debounce", "", "quiet period before restarting after a change (default 500ms)")
+	o.limits = &pb.ResourceLimits{}
+	fs.Func("nofile", "open files limit (RLIMIT_NOFILE)", func(s string) error {
+		v, err := strconv.ParseUint(s, 10, 64)
+		o.limits.NoFile = &v
+		return err
+	})
+	fs.Func("core", "core file size limit, e.g. 0 or 1G (RLIMIT_CORE)", func(s string) error {
+		v, err := parseSize(s)
+		o.limits.Core = &v
+		return err
+	})
+	fs.Func("as", "address space limit, e.g. 4G (RLIMIT_AS)", func(s string) error {
+		v, err := parseSize(s)
+		o.limits.AddressSpace = &v
+		return err
+	})
+	fs.Func("memory", "cgroup memory.max, e.g. 512M", func(s string) (err error) {
+		o.limits.MemoryMax, err = parseSize(s)
+		return err
+	})
+	fs.Float64Var(&o.limits.Cpus, "cpus", 0, "cgroup cpu.max in CPUs, e.g. 1.5")
+	fs.Int64Var(&o.limits.PidsMax, "pids", 0, "cgroup pids.max")
+	fs.Func("io-weight", "cgroup io.weight (1-10000)", func(s string) error {
+		v, err := strconv.ParseInt(s, 10, 32)
+		o.limits.IoWeight = int32(v)
+		return err
+	})
+	o.scheduling = &pb.Scheduling{}
+	fs.Func("nice", "niceness from -20 (highest priority) to 19", func(s string) error {
+		v, err := strconv.ParseInt(s, 10, 32)
+		nice := int32(v)
+		o.scheduling.Nice = &nice
+		return err
+	})
+	fs.Func("ionice", "IO priority as class[:level], class realtime|best-effort|idle, level 0-7", func(s string) error {
+		class, level, ok := strings.Cut(s, ":")
+		o.scheduling.IoClass = class
+		if ok {
+			v, err := strconv.ParseInt(level, 10, 32)
+			o.scheduling.IoLevel = int32(v)
+			return err
+		}
+		return nil
+	})
+	fs.Func("cpu-affinity", "CPUs the process may run on, e.g. 0-3,6", func(s string) error {
+		cpus, err := process.ParseCPUList(s)
+		for _, cpu := range cpus {
+			o.scheduling.Cpus = append(o.scheduling.Cpus, int32(cpu))
+		}
+		return err
+	})
+	o.labels = make(map[string]string)
+	fs.Func("label", "label as KEY=VALUE (repeatable)", func(s string) error {
+		key, value, ok := strings.Cut(s, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("expected KEY=VALUE, got %q", s)
+		}
+		o.labels[key] = value
+		return nil
+	})
+	fs.BoolVar(&o.tty, "tty", false, "run the process on a pseudo-terminal that can be attached to")
+	fs.BoolVar(&o.stdin, "stdin", false, "keep the process's stdin open for gopm send")
+	fs.Func("max-memory", "restart when memory use stays above this, e.g. 300M", func(s string) (err error) {
+		o.maxMemory, err = parseSize(s)
+		return err
+	})
+	fs.Float64Var(&o.maxCPU, "max-cpu", 0, "restart when CPU use stays above this percentage of one core")
+	fs.StringVar(&o.thresholdFor, "threshold-for", "", "how long -max-memory or -max-cpu must be exceeded (e.g. 30s)")
+	o.env = make(map[string]string)
+	fs.Func("env", "environment variable as KEY=VALUE (repeatable)", func(s string) error {
+		key, value, ok := strings.Cut(s, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("expected KEY=VALUE, got %q", s)
+		}
+		o.env[key] = value
+		return nil
+	})
+	return fs
+}
+
 func RunStart(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
-	fs := flag.NewFlagSet("start", flag.ContinueOnError)
-	var autoRestart string
-	fs.StringVar(&autoRestart, "auto-restart", "never", "auto restart policy (never|always|on-failure)")
+	var o startOptions
+	fs := startFlags(&o)
 
 	// e.g. `client start -auto-restart=always myapp ping google.com`
 	err := fs.Parse(args)
 	if err != nil {
-		// fmt.Println("bomba")
 		return err
 	}
 
 	subcommand := fs.Args()
+	if len(subcommand) == 1 && subcommand[0] == "all" {
+		res, err := client.StartProcess(ctx, &pb.StartRequest{Name: "all"})
+		if err != nil {
+			return err
+		}
+		return printResponse(res)
+	}
 	if len(subcommand) < 2 {
-		return fmt.Errorf("usage: client start <flag> <name> <cmd> [args...]")
+		return UsageError{"gopm start <flag> <name> <cmd> [args...] | gopm start all"}
 	}
 
 	name := subcommand[0]
 	cmdToRun := subcommand[1]
 	procArgs := subcommand[2:]
+
+	// the daemon has its own working directory, so send absolute paths
+	if o.cwd == "" {
+		if o.cwd, err = os.Getwd(); err != nil {
+			return err
+		}
+	}
+	if o.cwd, err = filepath.Abs(o.cwd); err != nil {
+		return err
+	}
+	for i, path := range o.watch {
+		if !filepath.IsAbs(path) {
+			o.watch[i] = filepath.Join(o.cwd, path)
+		}
+	}
+
+	spec := &pb.ProcessSpec{
+		Name:        name,
+		Command:     cmdToRun,
+		Args:        procArgs,
+		Env:         o.env,
+		AutoRestart: o.autoRestart,
+		DependsOn:   parseDependencies(o.dependsOn),
+		Instances:   int32(o.instances),
+		BasePort:    int32(o.basePort),
+		Cwd:         o.cwd,
+
+		MaxMemory:         o.maxMemory,
+		MaxCpu:            o.maxCPU,
+		ThresholdDuration: o.thresholdFor,
+		Tty:               o.tty,
+		Stdin:             o.stdin,
+		Labels:            o.labels,
+	}
+	if !proto.Equal(o.limits, &pb.ResourceLimits{}) {
+		spec.Limits = o.limits
+	}
+	if !proto.Equal(o.scheduling, &pb.Scheduling{}) {
+		spec.Scheduling = o.scheduling
+	}
+	if len(o.watch) > 0 {
+		spec.Watch = &pb.WatchSpec{Paths: o.watch, Ignore: o.ignore, Debounce: o.debounce}
+	}
+	if o.healthCmd != "" {
+		spec.HealthCheck = &pb.HealthCheck{Command: o.healthCmd, Interval: o.healthInterval}
+	}
 	req := &pb.StartRequest{
 		Name:        name,
 		Command:     cmdToRun,
 		Args:        procArgs,
-		AutoRestart: autoRestart,
+		AutoRestart: o.autoRestart,
+		Spec:        spec,
 	}
 	res, err := client.StartProcess(ctx, req)
 	if err != nil {
-		fmt.Println(name, cmdToRun, procArgs)
-		fmt.Println("bomba")
 		return err
 	}
-	fmt.Println(res.Message)
-	return nil
+	return printResponse(res)
 }
 
-func RunStop(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
-	fs := flag.NewFlagSet("stop", flag.ContinueOnError)
-	var force bool
-	fs.BoolVar(&force, "force", false, "force stop the process")
+// parseDependencies turns "cache:healthy,db" into dependency messages.
+func parseDependencies(s string) []*pb.Dependency {
+	var deps []*pb.Dependency
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, condition, _ := strings.Cut(item, ":")
+		deps = append(deps, &pb.Dependency{Name: name, Condition: condition})
+	}
+	return deps
+}
+
+// applyFlags returns the flag set of apply, which sets file.
+func applyFlags(file *string) *flag.FlagSet {
+	fs := newFlagSet("apply")
+	fs.StringVar(file, "f", "gopm.json", "config file describing the processes to run")
+	return fs
+}
+
+func RunApply(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
+	var file string
+	fs := applyFlags(&file)
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %v", err)
+	}
+	req := &pb.ApplyRequest{}
+	if err := protojson.Unmarshal(data, req); err != nil {
+		return fmt.Errorf("failed to parse config %s: %v", file, err)
+	}
+
+	res, err := client.Apply(ctx, req)
+	if err != nil {
+		return err
+	}
+	return printResponse(res)
+}
+
+func RunScale(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
+	fs := newFlagSet("scale")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	subcommand := fs.Args()
+	if len(subcommand) != 2 {
+		return UsageError{"gopm scale <name> <instances>"}
+	}
+	instances, err := strconv.Atoi(subcommand[1])
+	if err != nil {
+		return fmt.Errorf("invalid instance count %q", subcommand[1])
+	}
+
+	req := &pb.ScaleRequest{Name: subcommand[0], Instances: int32(instances)}
+	res, err := client.ScaleProcess(ctx, req)
+	if err != nil {
+		return err
+	}
+	return printResponse(res)
+}
+
+// signalOptions are the flags of signal.
+type signalOptions struct {
+	group    bool
+	selector string
+}
 
+func signalFlags(o *signalOptions) *flag.FlagSet {
+	fs := newFlagSet("signal")
+	fs.BoolVar(&o.group, "group", false, "signal the whole process group instead of the main pid")
+	fs.StringVar(&o.selector, "l", "", selectorUsage)
+	return fs
+}
+
+func RunSignal(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
+	var o signalOptions
+	fs := signalFlags(&o)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	args = fs.Args()
+	if o.selector != "" && len(args) == 1 {
+		args = []string{"all", args[0]}
+	}
+	if len(args) != 2 {
+		return UsageError{"gopm signal <flag> <name|glob|all> <signal>"}
+	}
+
+	req := &pb.SignalRequest{Name: args[0], Signal: args[1], Group: o.group, Selector: o.selector}
+	res, err := client.SignalProcess(ctx, req)
+	if err != nil {
+		return err
+	}
+	return printResponse(res)
+}
+
+// sendOptions are the flags of send.
+type sendOptions struct {
+	file           string
+	noNewline, eof bool
+}
+
+func sendFlags(o *sendOptions) *flag.FlagSet {
+	fs := newFlagSet("send")
+	fs.StringVar(&o.file, "f", "", "stream this file to stdin instead, - for this command's stdin")
+	fs.BoolVar(&o.noNewline, "n", false, "don't append a newline to the text")
+	fs.BoolVar(&o.eof, "eof", false, "close the process's stdin afterwards")
+	return fs
+}
+
+func RunSend(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
+	var o sendOptions
+	fs := sendFlags(&o)
 	err := fs.Parse(args)
 	if err != nil {
 		return err
 	}
 
 	subcommand := fs.Args()
-	if len(subcommand) < 1 {
-		return fmt.Errorf("usage: client stop <flag> <name>")
+	if len(subcommand) != 2 && !(len(subcommand) == 1 && (o.file != "" || o.eof)) {
+		return UsageError{"gopm send <flag> <name> <text> | gopm send -f <file> <name>"}
 	}
 
+	stream, err := client.WriteStdin(ctx)
+	if err != nil {
+		return err
+	}
 	name := subcommand[0]
+	if err := stream.Send(&pb.StdinRequest{Name: name}); err != nil {
+		return err
+	}
+
+	if len(subcommand) == 2 {
+		text := subcommand[1]
+		if !o.noNewline {
+			text += "\n"
+		}
+		if err := stream.Send(&pb.StdinRequest{Data: []byte(text)}); err != nil {
+			return err
+		}
+	}
+	if o.file != "" {
+		in := os.Stdin
+		if o.file != "-" {
+			if in, err = os.Open(o.file); err != nil {
+				return err
+			}
+			defer in.Close()
+		}
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := in.Read(buf)
+			if n > 0 {
+				if err := stream.Send(&pb.StdinRequest{Data: buf[:n]}); err != nil {
+					break
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if o.eof {
+		if err := stream.Send(&pb.StdinRequest{Close: true}); err != nil && err != io.EOF {
+			return err
+		}
+	}
+
+	// a failed send shows up as the error here
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	return printResponse(res)
+}
+
+// restartOptions are the flags of restart.
+type restartOptions struct {
+	force, rolling          bool
+	batchSize               int
+	minUptime, readyTimeout string
+	selector                string
+}
+
+func restartFlags(o *restartOptions) *flag.FlagSet {
+	fs := newFlagSet("restart")
+	fs.BoolVar(&o.force, "force", false, "kill instead of stopping gracefully (ignored with --rolling)")
+	fs.BoolVar(&o.rolling, "rolling", false, "restart instances a batch at a time, waiting for each to become ready")
+	fs.IntVar(&o.batchSize, "batch", 1, "instances restarted at once during a rolling restart")
+	fs.StringVar(&o.minUptime, "min-uptime", "", "uptime after which an instance without a health check counts as ready (default 5s)")
+	fs.StringVar(&o.readyTimeout, "ready-timeout", "", "how long to wait for an instance to become ready (default 60s)")
+	fs.StringVar(&o.selector, "l", "", selectorUsage)
+	return fs
+}
+
+func RunRestart(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
+	var o restartOptions
+	fs := restartFlags(&o)
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	name, rest, ok := selectTarget(fs.Args(), o.selector)
+	if !ok || len(rest) > 0 {
+		return UsageError{"gopm restart <flag> <name|glob|all>"}
+	}
+
+	req := &pb.RestartRequest{
+		Name:         name,
+		Selector:     o.selector,
+		Force:        o.force,
+		Rolling:      o.rolling,
+		BatchSize:    int32(o.batchSize),
+		MinUptime:    o.minUptime,
+		ReadyTimeout: o.readyTimeout,
+	}
+	stream, err := client.RestartProcess(ctx, req)
+	if err != nil {
+		return err
+	}
+	namespace := CurrentNamespace()
+	failed := false
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		for _, r := range res.Results {
+			failed = failed || !r.Success
+		}
+		if machineOutput() {
+			if err := printMessage(res); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, r := range res.Results {
+			printResult(r, namespace)
+		}
+		if res.Message != "" {
+			fmt.Println(res.Message)
+		}
+	}
+	if failed {
+		return fmt.Errorf("some processes failed to restart")
+	}
+	return nil
+}
+
+// scheduleOptions are the flags of schedule.
+type scheduleOptions struct {
+	concurrency, timeout string
+	history              int
+}
+
+func scheduleFlags(o *scheduleOptions) *flag.FlagSet {
+	fs := newFlagSet("schedule")
+	fs.StringVar(&o.concurrency, "concurrency", "allow", "what to do when a run is due while the previous one is going (allow|forbid|replace)")
+	fs.StringVar(&o.timeout, "timeout", "", "kill runs that take longer than this (e.g. 10m)")
+	fs.IntVar(&o.history, "history", 10, "number of finished runs to keep")
+	return fs
+}
+
+func RunSchedule(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
+	var o scheduleOptions
+	fs := scheduleFlags(&o)
+
+	// e.g. `client schedule -concurrency=forbid backup "0 3 * * *" ./backup.sh`
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	subcommand := fs.Args()
+	if len(subcommand) < 3 {
+		return UsageError{"gopm schedule <flag> <name> <schedule> <cmd> [args...]"}
+	}
+
+	req := &pb.JobSpec{
+		Name:              subcommand[0],
+		Schedule:          subcommand[1],
+		Command:           subcommand[2],
+		Args:              subcommand[3:],
+		ConcurrencyPolicy: o.concurrency,
+		Timeout:           o.timeout,
+		HistoryLimit:      int32(o.history),
+	}
+	res, err := client.ScheduleJob(ctx, req)
+	if err != nil {
+		return err
+	}
+	return printResponse(res)
+}
+
+// ExitCodeError asks the CLI to exit with Code, e.g. to pass on the exit
+// code of a task.
+type ExitCodeError struct {
+	Code int
+}
+
+func (e ExitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// taskOptions are the flags of run.
+type taskOptions struct {
+	wait         bool
+	timeout, ttl string
+}
+
+func taskFlags(o *taskOptions) *flag.FlagSet {
+	fs := newFlagSet("run")
+	fs.BoolVar(&o.wait, "wait", false, "stream the output and exit with the task's exit code")
+	fs.StringVar(&o.timeout, "timeout", "", "kill the task if it takes longer than this")
+	fs.StringVar(&o.ttl, "ttl", "", "how long the daemon keeps the result (default 24h, 0 keeps it until removed)")
+	return fs
+}
+
+func RunTask(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
+	var o taskOptions
+	fs := taskFlags(&o)
+
+	// e.g. `client run -wait migrate ./manage.py migrate`
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	subcommand := fs.Args()
+	if len(subcommand) < 2 {
+		return UsageError{"gopm run <flag> <name> <cmd> [args...]"}
+	}
+
+	req := &pb.RunTaskRequest{
+		Name:    subcommand[0],
+		Command: subcommand[1],
+		Args:    subcommand[2:],
+		Timeout: o.timeout,
+		Ttl:     o.ttl,
+		Wait:    o.wait,
+	}
+	stream, err := client.RunTask(ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		out, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if machineOutput() {
+			if err := printMessage(out); err != nil {
+				return err
+			}
+		} else if out.Result == nil {
+			fmt.Println(out.Text)
+		}
+		if out.Result == nil {
+			continue
+		}
+
+		result := out.Result
+		if !machineOutput() {
+			duration := result.FinishedAt.AsTime().Sub(result.StartedAt.AsTime())
+			fmt.Fprintf(os.Stderr, "task %s %s after %s (exit code %d)\n", req.Name, result.Status, duration.Round(time.Millisecond), result.ExitCode)
+		}
+		if result.ExitCode != 0 {
+			code := int(result.ExitCode)
+			if code < 0 {
+				code = 1
+			}
+			return ExitCodeError{Code: code}
+		}
+		return nil
+	}
+}
+
+// stopOptions are the flags of stop.
+type stopOptions struct {
+	force    bool
+	selector string
+}
+
+func stopFlags(o *stopOptions) *flag.FlagSet {
+	fs := newFlagSet("stop")
+	fs.BoolVar(&o.force, "force", false, "force stop the process")
+	fs.StringVar(&o.selector, "l", "", selectorUsage)
+	return fs
+}
+
+func RunStop(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
+	var o stopOptions
+	fs := stopFlags(&o)
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	name, rest, ok := selectTarget(fs.Args(), o.selector)
+	if !ok || len(rest) > 0 {
+		return UsageError{"gopm stop <flag> <name|glob|all>"}
+	}
 	req := &pb.StopRequest{
-		Name:  name,
-		Force: force,
+		Name:     name,
+		Force:    o.force,
+		Selector: o.selector,
 	}
 	res, err := client.StopProcess(ctx, req)
 	if err != nil {
 		return err
 	}
-	fmt.Println(res.Message)
-	return nil
+	return printResponse(res)
+}
+
+// listOptions are the flags of list.
+type listOptions struct {
+	verbose, allNamespaces bool
+	selector               string
+}
+
+func listFlags(o *listOptions) *flag.FlagSet {
+	fs := newFlagSet("list")
+	fs.BoolVar(&o.verbose, "verbose", false, "show more information")
+	fs.StringVar(&o.selector, "l", "", selectorUsage)
+	fs.BoolVar(&o.allNamespaces, "all-namespaces", false, "list processes in every namespace")
+	return fs
 }
 
 func RunList(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
-	fs := flag.NewFlagSet("list", flag.ContinueOnError)
-	var verbose bool
-	fs.BoolVar(&verbose, "verbose", false, "show more information")
+	var o listOptions
+	fs := listFlags(&o)
 
 	err := fs.Parse(args)
 	if err != nil {
 		return err
 	}
+	if fs.NArg() > 1 {
+		return UsageError{"gopm list <flag> [name|glob]"}
+	}
 
-	req := &pb.ListRequest{Verbose: verbose}
+	req := &pb.ListRequest{Verbose: o.verbose, Name: fs.Arg(0), Selector: o.selector, AllNamespaces: o.allNamespaces}
+	// usage is only sampled into verbose listings
+	if output.kind == OutputWide {
+		req.Verbose = true
+	}
 	res, err := client.ListProcess(ctx, req)
 	if err != nil {
 		return err
 	}
-	if len(res.Processes) == 0 {
+	if machineOutput() {
+		return printMessage(res)
+	}
+	// names are shown relative to the current namespace unless listing all
+	namespace := CurrentNamespace()
+	display := func(name string) string { return shortName(name, namespace) }
+	if o.allNamespaces {
+		display = func(name string) string { return name }
+	}
+	if tableOutput() {
+		printTable(res, display)
+		return nil
+	}
+	if len(res.Processes) == 0 && len(res.Jobs) == 0 && len(res.Tasks) == 0 {
 		fmt.Println("no running processes.")
 	} else {
 		for _, p := range res.Processes {
-			fmt.Printf("name: %s, PID: %d, status: %s\n", p.Name, p.Pid, p.Status)
+			status := p.Status
+			if p.ExitReason != "" && p.Status != "running" {
+				status = fmt.Sprintf("%s (%s)", p.Status, formatExit(p))
+			}
+			fmt.Printf("%sname: %s, PID: %d, status: %s\n", hostPrefix(p.Host), display(p.Name), p.Pid, status)
+			if o.verbose && len(p.Labels) > 0 {
+				fmt.Printf("  labels: %s\n", formatLabels(p.Labels))
+			}
+			if o.verbose && p.ExitReason != "" && p.Status == "running" {
+				fmt.Printf("  last exit: %s\n", formatExit(p))
+			}
+			if o.verbose {
+				fmt.Printf("  cpu: %.1f%%, memory: %s, restarts: %d\n", p.CpuPercent, formatBytes(p.MemoryBytes), p.Restarts)
+				if p.RestartReason != "" {
+					fmt.Printf("  last restart: %s\n", p.RestartReason)
+				}
+			}
+			if l := p.Limits; l != nil {
+				fmt.Printf("  limits: %s\n", formatLimits(l))
+			}
+			if s := p.Scheduling; s != nil {
+				fmt.Printf("  scheduling: %s\n", formatScheduling(s))
+			}
+			if u := p.Usage; u != nil {
+				fmt.Printf("  cgroup: %s memory=%s cpu=%s pids=%d\n", u.Path, formatBytes(u.MemoryCurrent),
+					time.Duration(u.CpuUsageUsec)*time.Microsecond, u.PidsCurrent)
+			}
+		}
+	}
+	for _, t := range res.Tasks {
+		result := t.Result
+		summary := result.Status
+		if result.FinishedAt != nil {
+			duration := result.FinishedAt.AsTime().Sub(result.StartedAt.AsTime())
+			summary = fmt.Sprintf("%s (exit code %d) after %s", result.Status, result.ExitCode, duration.Round(time.Millisecond))
+		}
+		fmt.Printf("%stask: %s, status: %s\n", hostPrefix(t.Host), display(t.Name), summary)
+	}
+	for _, j := range res.Jobs {
+		last := "never run"
+		if len(j.Runs) > 0 {
+			run := j.Runs[len(j.Runs)-1]
+			last = fmt.Sprintf("%s (exit code %d)", run.Status, run.ExitCode)
+		}
+		next := "-"
+		if j.NextRun != nil {
+			next = j.NextRun.AsTime().Local().Format(time.DateTime)
+		}
+		fmt.Printf("%sjob: %s, schedule: %s, next run: %s, last run: %s\n", hostPrefix(j.Host), display(j.Spec.Name), j.Spec.Schedule, next, last)
+
+		if o.verbose {
+			for _, run := range j.Runs {
+				finished := "-"
+				if run.FinishedAt != nil {
+					finished = run.FinishedAt.AsTime().Local().Format(time.TimeOnly)
+				}
+				fmt.Printf("  run %d: %s, exit code %d, started %s, finished %s\n",
+					run.Id, run.Status, run.ExitCode,
+					run.StartedAt.AsTime().Local().Format(time.DateTime), finished)
+			}
 		}
 	}
 	return nil
 }
 
+// logOptions are the flags of log.
+type logOptions struct {
+	follow   bool
+	run      int
+	selector string
+}
+
+func logFlags(o *logOptions) *flag.FlagSet {
+	fs := newFlagSet("log")
+	fs.BoolVar(&o.follow, "follow", false, "follow logs in real time")
+	fs.IntVar(&o.run, "run", 0, "for jobs, the run to show (default: the latest)")
+	fs.StringVar(&o.selector, "l", "", selectorUsage)
+	return fs
+}
+
 func RunLogs(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
-	fs := flag.NewFlagSet("log", flag.ContinueOnError)
-	var follow bool
-	fs.BoolVar(&follow, "follow", false, "follow logs in real time")
+	var o logOptions
+	fs := logFlags(&o)
 
 	err := fs.Parse(args)
 	if err != nil {
 		return err
 	}
 
-	subcommand := fs.Args()
-	if len(subcommand) < 1 || len(subcommand) > 2 {
-		return fmt.Errorf("usage: client log <flag>")
+	name, rest, ok := selectTarget(fs.Args(), o.selector)
+	if !ok || len(rest) > 1 {
+		return UsageError{"gopm log <flag> <name|glob|all>"}
 	}
-	name := subcommand[0]
 
-	req := &pb.LogRequest{Name: name, Follow: follow}
+	req := &pb.LogRequest{Name: name, Follow: o.follow, Run: int32(o.run), Selector: o.selector}
 	stream, err := client.StreamLogs(ctx, req)
 	if err != nil {
-		return fmt.Errorf("failed to open log stream %s", err)
+		return err
 	}
+	namespace := CurrentNamespace()
 
 	for {
 		line, err := stream.Recv()
@@ -160,17 +900,46 @@ func RunLogs(client pb.ProcessManagerClient, ctx context.Context, args []string)
 			break
 		}
 		if err != nil {
-			return fmt.Errorf("error recieving log data: %v", err)
+			return err
+		}
+		switch {
+		case machineOutput():
+			if err := printMessage(line); err != nil {
+				return err
+			}
+		case tableOutput():
+			process := line.Process
+			if process == "" {
+				process = name
+			}
+			if fanningOut() {
+				fmt.Printf("%-20s ", line.Host)
+			}
+			fmt.Printf("%-20s %s\n", shortName(process, namespace), line.Text)
+		case line.Process != "":
+			fmt.Printf("%s[%s] %s\n", hostPrefix(line.Host), shortName(line.Process, namespace), line.Text)
+		default:
+			fmt.Println(hostPrefix(line.Host) + line.Text)
 		}
-		fmt.Println(line.Text)
 	}
 	return nil
 }
 
-func RunRemove(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
-	fs := flag.NewFlagSet("remove", flag.ContinueOnError)
-	var follow bool
-	fs.BoolVar(&follow, "no-stop", false, "remove process without stopping it")
+// eventsOptions are the flags of events.
+type eventsOptions struct {
+	follow, allNamespaces bool
+}
+
+func eventsFlags(o *eventsOptions) *flag.FlagSet {
+	fs := newFlagSet("events")
+	fs.BoolVar(&o.follow, "follow", false, "follow events in real time")
+	fs.BoolVar(&o.allNamespaces, "all-namespaces", false, "show events from every namespace")
+	return fs
+}
+
+func RunEvents(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
+	var o eventsOptions
+	fs := eventsFlags(&o)
 
 	err := fs.Parse(args)
 	if err != nil {
@@ -178,17 +947,262 @@ func RunRemove(client pb.ProcessManagerClient, ctx context.Context, args []strin
 	}
 
 	subcommand := fs.Args()
-	if len(subcommand) < 1 || len(subcommand) > 3 {
-		return fmt.Errorf("usage: client remove <flag> <name>")
+	if len(subcommand) > 1 {
+		return UsageError{"gopm events <flag> [name]"}
+	}
+	req := &pb.EventRequest{Follow: o.follow, AllNamespaces: o.allNamespaces}
+	if len(subcommand) == 1 {
+		req.Name = subcommand[0]
 	}
 
-	name := subcommand[0]
+	stream, err := client.StreamEvents(ctx, req)
+	if err != nil {
+		return err
+	}
+	// followed events arrive one at a time, so the table can't be aligned
+	// as a whole
+	header := false
+	namespace := CurrentNamespace()
+	for {
+		e, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case machineOutput():
+			if err := printMessage(e); err != nil {
+				return err
+			}
+		case tableOutput():
+			if !header {
+				if fanningOut() {
+					fmt.Printf("%-20s  ", "HOST")
+				}
+				fmt.Printf("%-19s  %-20s  %-12s  %s\n", "TIME", "PROCESS", "TYPE", "MESSAGE")
+				header = true
+			}
+			if fanningOut() {
+				fmt.Printf("%-20s  ", e.Host)
+			}
+			fmt.Printf("%-19s  %-20s  %-12s  %s\n", e.Time.AsTime().Local().Format(time.DateTime), shortName(e.Process, namespace), e.Type, e.Message)
+		default:
+			fmt.Printf("%s%s %s %s: %s\n", hostPrefix(e.Host), e.Time.AsTime().Local().Format(time.DateTime), shortName(e.Process, namespace), e.Type, e.Message)
+		}
+	}
+}
+
+// removeOptions are the flags of remove.
+type removeOptions struct {
+	noStop   bool
+	selector string
+}
+
+func removeFlags(o *removeOptions) *flag.FlagSet {
+	fs := newFlagSet("remove")
+	fs.BoolVar(&o.noStop, "no-stop", false, "remove process without stopping it")
+	fs.StringVar(&o.selector, "l", "", selectorUsage)
+	return fs
+}
 
-	req := &pb.RemoveRequest{Name: name}
+func RunRemove(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
+	var o removeOptions
+	fs := removeFlags(&o)
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	name, rest, ok := selectTarget(fs.Args(), o.selector)
+	if !ok || len(rest) > 2 {
+		return UsageError{"gopm remove <flag> <name|glob|all>"}
+	}
+
+	req := &pb.RemoveRequest{Name: name, Selector: o.selector}
 	res, err := client.RemoveProcess(ctx, req)
 	if err != nil {
 		return err
 	}
-	fmt.Println(res.Message)
+	return printResponse(res)
+}
+
+const selectorUsage = "label selector, e.g. team=payments,tier!=db"
+
+// selectTarget splits the name a command acts on from the rest of its
+// arguments. With a label selector the name may be left out and defaults
+// to all processes.
+func selectTarget(args []string, selector string) (string, []string, bool) {
+	if len(args) > 0 {
+		return args[0], args[1:], true
+	}
+	if selector != "" {
+		return "all", nil, true
+	}
+	return "", nil, false
+}
+
+// printResponse prints the message of a response, preceded by the
+// per-process results of a bulk request. It fails if any of them failed.
+func printResponse(res *pb.ProcessResponse) error {
+	namespace := CurrentNamespace()
+	failed := 0
+	for _, r := range res.Results {
+		if !r.Success {
+			failed++
+		}
+	}
+	switch {
+	case machineOutput():
+		if err := printMessage(res); err != nil {
+			return err
+		}
+	case tableOutput() && len(res.Results) > 0:
+		header := []string{"NAME", "RESULT", "MESSAGE"}
+		if fanningOut() {
+			header = append([]string{"HOST"}, header...)
+		}
+		w := newTable(header...)
+		for _, r := range res.Results {
+			result := "ok"
+			if !r.Success {
+				result = "failed"
+			}
+			if fanningOut() {
+				fmt.Fprintf(w, "%s\t", r.Host)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", orDefault(shortName(r.Name, namespace), "-"), result, r.Message)
+		}
+		w.Flush()
+		if res.Message != "" {
+			fmt.Println(res.Message)
+		}
+	default:
+		for _, r := range res.Results {
+			printResult(r, namespace)
+		}
+		if res.Message != "" {
+			fmt.Println(res.Message)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d process(es) failed", failed)
+	}
 	return nil
 }
+
+func printResult(r *pb.ProcessResult, namespace string) {
+	prefix := hostPrefix(r.Host)
+	if r.Name != "" {
+		prefix += shortName(r.Name, namespace) + ": "
+	}
+	if r.Success {
+		fmt.Printf("%s%s\n", prefix, r.Message)
+	} else {
+		fmt.Printf("%sfailed: %s\n", prefix, r.Message)
+	}
+}
+
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key + "=" + labels[key]
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseSize parses byte sizes such as "512", "64K", "512M" or "2GiB".
+func parseSize(s string) (uint64, error) {
+	units := []struct {
+		suffix string
+		scale  uint64
+	}{
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+	}
+	trimmed := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B"), "I")
+	for _, unit := range units {
+		if number, ok := strings.CutSuffix(trimmed, unit.suffix); ok {
+			v, err := strconv.ParseFloat(number, 64)
+			if err != nil || v < 0 {
+				return 0, fmt.Errorf("invalid size %q", s)
+			}
+			return uint64(v * float64(unit.scale)), nil
+		}
+	}
+	v, err := strconv.ParseUint(trimmed, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return v, nil
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := uint64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func formatLimits(l *pb.ResourceLimits) string {
+	var parts []string
+	if l.NoFile != nil {
+		parts = append(parts, fmt.Sprintf("nofile=%d", *l.NoFile))
+	}
+	if l.Core != nil {
+		parts = append(parts, "core="+formatBytes(*l.Core))
+	}
+	if l.AddressSpace != nil {
+		parts = append(parts, "as="+formatBytes(*l.AddressSpace))
+	}
+	if l.MemoryMax > 0 {
+		parts = append(parts, "memory="+formatBytes(l.MemoryMax))
+	}
+	if l.Cpus > 0 {
+		parts = append(parts, fmt.Sprintf("cpus=%g", l.Cpus))
+	}
+	if l.PidsMax > 0 {
+		parts = append(parts, fmt.Sprintf("pids=%d", l.PidsMax))
+	}
+	if l.IoWeight > 0 {
+		parts = append(parts, fmt.Sprintf("io-weight=%d", l.IoWeight))
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatExit(p *pb.ProcessInfo) string {
+	if p.ExitSignal != "" {
+		return fmt.Sprintf("%s, signal %s", p.ExitReason, p.ExitSignal)
+	}
+	return fmt.Sprintf("%s, exit code %d", p.ExitReason, p.ExitCode)
+}
+
+func formatScheduling(s *pb.Scheduling) string {
+	var parts []string
+	if s.Nice != nil {
+		parts = append(parts, fmt.Sprintf("nice=%d", *s.Nice))
+	}
+	if s.IoClass != "" {
+		parts = append(parts, fmt.Sprintf("io=%s:%d", s.IoClass, s.IoLevel))
+	}
+	if len(s.Cpus) > 0 {
+		cpus := make([]int, len(s.Cpus))
+		for i, cpu := range s.Cpus {
+			cpus[i] = int(cpu)
+		}
+		parts = append(parts, "cpus="+process.FormatCPUList(cpus))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,431 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brianykl/gopm/internal/process"
+	"github.com/brianykl/gopm/internal/server"
+	pb "github.com/brianykl/gopm/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Version is the version of gopm, set at build time with
+// -ldflags "-X github.com/brianykl/gopm/internal/utils.Version=v1.2.3".
+var Version = ""
+
+// Exit codes of the CLI. `gopm run --wait` exits with the task's code
+// instead.
+const (
+	ExitOK          = 0
+	ExitFailure     = 1
+	ExitUsage       = 2
+	ExitUnreachable = 3
+)
+
+const defaultAddress = "localhost:50051"
+
+// Command is a gopm subcommand.
+type Command struct {
+	Name    string
+	Aliases []string
+	// Usage shows the arguments, e.g. "[flags] <name>".
+	Usage   string
+	Summary string
+	// Run runs a command that talks to the daemon; Local runs one that
+	// doesn't, so that it works without one.
+	Run   func(client pb.ProcessManagerClient, ctx context.Context, args []string) error
+	Local func(args []string) error
+	// Streaming commands run until they are done rather than within
+	// --timeout, unless it is given explicitly.
+	Streaming bool
+	// FanOut commands can run against several daemons with --hosts.
+	FanOut bool
+	Hidden bool
+	// Flags returns the flag set the command parses, for help and
+	// completion. It is nil for commands without flags of their own.
+	Flags func() *flag.FlagSet
+	// Subcommands are the words the arguments may start with. Each parses
+	// flags of its own, so that `<subcommand> -h` shows them.
+	Subcommands []string
+	// SubcommandFlags returns the flag sets of the subcommands whose flags
+	// differ from the command's.
+	SubcommandFlags map[string]func() *flag.FlagSet
+}
+
+// flagSet returns the flag set of c, without running it.
+func (c *Command) flagSet() *flag.FlagSet {
+	switch {
+	case c.Flags != nil:
+		return c.Flags()
+	case c.Run != nil:
+		return newFlagSet(c.Name)
+	}
+	return commandFlagSet(c.Name)
+}
+
+// flagsOf returns the Flags of a command whose flag set, made by newFlags,
+// fills in a T.
+func flagsOf[T any](newFlags func(*T) *flag.FlagSet) func() *flag.FlagSet {
+	return func() *flag.FlagSet {
+		return newFlags(new(T))
+	}
+}
+
+// commands is set in init, as the commands refer back to it for help.
+var commands []*Command
+
+func init() {
+	commands = []*Command{
+		{Name: "init", Usage: "[flags]", Summary: "run the daemon in the foreground", Local: RunServer, Flags: initFlags("init")},
+		{Name: "init-bg", Usage: "[flags]", Summary: "run the daemon in the background", Local: RunServerInBackground, Flags: initFlags("init-bg")},
+
+		{Name: "start", Usage: "[flags] <name> <cmd> [args...] | all", Summary: "start a process", Run: RunStart, Flags: flagsOf(startFlags), FanOut: true},
+		{Name: "stop", Usage: "[flags] <name|glob|all>", Summary: "stop processes", Run: RunStop, Flags: flagsOf(stopFlags), FanOut: true},
+		{Name: "restart", Usage: "[flags] <name|glob|all>", Summary: "restart processes, optionally one batch at a time", Run: RunRestart, Flags: flagsOf(restartFlags), Streaming: true, FanOut: true},
+		{Name: "signal", Usage: "[flags] <name|glob|all> <signal>", Summary: "send a signal to processes", Run: RunSignal, Flags: flagsOf(signalFlags), FanOut: true},
+		{Name: "scale", Usage: "<name> <instances>", Summary: "change the number of replicas of a process", Run: RunScale, FanOut: true},
+		{Name: "remove", Usage: "[flags] <name|glob|all>", Summary: "stop and forget processes, jobs or tasks", Run: RunRemove, Flags: flagsOf(removeFlags), FanOut: true},
+		{Name: "apply", Usage: "[flags]", Summary: "start or update the processes and jobs of a config file", Run: RunApply, Flags: flagsOf(applyFlags), FanOut: true},
+
+		{Name: "list", Aliases: []string{"status"}, Usage: "[flags] [name|glob]", Summary: "list processes, jobs and tasks", Run: RunList, Flags: flagsOf(listFlags), FanOut: true},
+		{Name: "describe", Usage: "[flags] <name>", Summary: "show everything known about a process", Run: RunDescribe, Flags: flagsOf(describeFlags)},
+		{Name: "log", Usage: "[flags] <name|glob|all>", Summary: "show the output of processes", Run: RunLogs, Flags: flagsOf(logFlags), Streaming: true, FanOut: true},
+		{Name: "events", Usage: "[flags] [name]", Summary: "show lifecycle events", Run: RunEvents, Flags: flagsOf(eventsFlags), Streaming: true, FanOut: true},
+		{Name: "monit", Usage: "[flags]", Summary: "full-screen dashboard", Run: RunMonit, Flags: flagsOf(monitFlags), Streaming: true},
+		{Name: "attach", Usage: "[flags] <name>", Summary: "connect the terminal to a process started with --tty", Run: RunAttach, Flags: flagsOf(attachFlags), Streaming: true},
+		{Name: "send", Usage: "[flags] <name> <text>", Summary: "write to the stdin of a process", Run: RunSend, Flags: flagsOf(sendFlags), Streaming: true},
+
+		{Name: "schedule", Usage: "[flags] <name> <schedule> <cmd> [args...]", Summary: "run a command periodically", Run: RunSchedule, Flags: flagsOf(scheduleFlags), FanOut: true},
+		{Name: "run", Usage: "[flags] <name> <cmd> [args...]", Summary: "run a one-shot task", Run: RunTask, Flags: flagsOf(taskFlags), Streaming: true},
+
+		{Name: "startup", Usage: "[systemd] [flags]", Summary: "install a service that runs the daemon at boot and resurrects its processes", Local: RunStartup, Flags: flagsOf(startupFlags), Subcommands: []string{"systemd"}},
+		{Name: "unstartup", Usage: "[systemd] [flags]", Summary: "remove the service installed by startup", Local: RunUnstartup, Flags: flagsOf(unstartupFlags), Subcommands: []string{"systemd"}},
+
+		{Name: "namespace", Usage: "[name]", Summary: "show or switch the current namespace", Local: RunNamespace},
+		{Name: "context", Usage: "<current|list|use|set|group|remove> [name] [flags]", Summary: "show, switch or edit the daemons to talk to", Local: RunContext,
+			Subcommands: []string{"current", "list", "use", "set", "group", "remove"}, SubcommandFlags: map[string]func() *flag.FlagSet{"set": flagsOf(setContextFlags)}},
+		{Name: "import", Usage: "[flags] <file>", Summary: "convert a Procfile, pm2 ecosystem file or supervisord config for apply", Local: RunImport, Flags: flagsOf(importFlags)},
+		{Name: "config", Usage: "validate [flags]", Summary: "check a config file without applying it", Local: RunConfig,
+			Subcommands: []string{"validate"}, SubcommandFlags: map[string]func() *flag.FlagSet{"validate": flagsOf(validateFlags)}},
+		{Name: "completion", Usage: "<bash|zsh|fish>", Summary: "print a shell completion script", Local: RunCompletion},
+		{Name: "version", Summary: "print the version", Local: RunVersion},
+		{Name: "help", Usage: "[command]", Summary: "show help for gopm or a command", Local: runHelp},
+		{Name: "__complete", Run: RunComplete, Hidden: true},
+	}
+}
+
+func findCommand(name string) *Command {
+	for _, c := range commands {
+		if c.Name == name {
+			return c
+		}
+		for _, alias := range c.Aliases {
+			if alias == name {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// global flags
+var (
+	address       string
+	timeout       time.Duration
+	namespaceFlag string
+)
+
+// usageShown is set once a flag set has printed its usage, which it does
+// for -h and for invalid flags.
+var usageShown bool
+
+// commandFlagSet returns the flag set of a command, whose usage describes
+// the command.
+func commandFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		usageShown = true
+		out := fs.Output()
+		if c := findCommand(name); c != nil {
+			fmt.Fprintf(out, "usage: gopm %s %s\n\n%s.\n", c.Name, c.Usage, capitalize(c.Summary))
+			if len(c.Aliases) > 0 {
+				fmt.Fprintf(out, "\naliases: %s\n", strings.Join(c.Aliases, ", "))
+			}
+		}
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintln(out, "\nflags:")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// UsageError reports a command invoked with the wrong arguments.
+type UsageError struct {
+	Usage string
+}
+
+func (e UsageError) Error() string {
+	return "usage: " + e.Usage
+}
+
+// globalFlagSet returns the flags given before the command.
+func globalFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("gopm", flag.ContinueOnError)
+	fs.StringVar(&contextFlag, "context", "", "context to use instead of the current one")
+	fs.StringVar(&hostsFlag, "hosts", "", "run the command against these comma separated contexts, addresses or host groups")
+	fs.StringVar(&address, "address", "", "address of the daemon, host:port or unix:/path (default: $GOPM_ADDRESS, else the context's, else "+defaultAddress+")")
+	fs.DurationVar(&timeout, "timeout", 5*time.Second, "how long to wait for the daemon; streaming commands wait indefinitely unless set")
+	fs.StringVar(&namespaceFlag, "namespace", "", "namespace to act in instead of the current one")
+	fs.Func("output", outputUsage, SetOutput)
+	fs.Func("o", "shorthand for --output", SetOutput)
+	fs.Usage = func() { printHelp(fs) }
+	return fs
+}
+
+// Execute runs the command line args, the arguments after the program
+// name, and returns the exit code.
+func Execute(args []string) int {
+	fs := globalFlagSet()
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	timeoutSet := false
+	fs.Visit(func(f *flag.Flag) { timeoutSet = timeoutSet || f.Name == "timeout" })
+
+	if fs.NArg() == 0 {
+		fs.SetOutput(os.Stderr)
+		printHelp(fs)
+		return ExitUsage
+	}
+	name := fs.Arg(0)
+	c := findCommand(name)
+	if c == nil {
+		fmt.Fprintf(os.Stderr, "error: unknown command %q, see gopm help\n", name)
+		return ExitUsage
+	}
+	helpFlags = fs
+
+	var err error
+	switch {
+	case c.Local != nil:
+		err = c.Local(fs.Args()[1:])
+	case hostsFlag != "":
+		if !c.FanOut {
+			fmt.Fprintf(os.Stderr, "error: %s acts on a single daemon and doesn't take --hosts\n", c.Name)
+			return ExitUsage
+		}
+		return runFanOut(c, fs.Args()[1:], timeoutSet)
+	default:
+		err = runRemote(c, fs.Args()[1:], timeoutSet)
+	}
+	return exitCode(err)
+}
+
+// helpFlags are the global flags, for gopm help.
+var helpFlags *flag.FlagSet
+
+func runRemote(c *Command, args []string, timeoutSet bool) error {
+	_, daemon, err := currentContext()
+	if err != nil {
+		return err
+	}
+	address = daemonAddress(daemon)
+	conn, err := dial(address, daemon, CurrentNamespace())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := commandContext(c, timeoutSet)
+	defer cancel()
+	return c.Run(pb.NewProcessManagerClient(conn), ctx, args)
+}
+
+// runFanOut runs a command against every host of --hosts and returns the
+// exit code, which is non-zero if any of them failed.
+func runFanOut(c *Command, args []string, timeoutSet bool) int {
+	hosts, err := resolveHosts(hostsFlag)
+	if err != nil {
+		return exitCode(err)
+	}
+	client, closeAll, err := dialHosts(hosts)
+	if err != nil {
+		return exitCode(err)
+	}
+	defer closeAll()
+
+	ctx, cancel := commandContext(c, timeoutSet)
+	defer cancel()
+	code := exitCode(c.Run(client, ctx, args))
+	if code == ExitOK && client.failed {
+		return ExitFailure
+	}
+	return code
+}
+
+func commandContext(c *Command, timeoutSet bool) (context.Context, context.CancelFunc) {
+	if !c.Streaming || timeoutSet {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// exitCode reports err and returns the exit code for it.
+func exitCode(err error) int {
+	var exitErr ExitCodeError
+	var usageErr UsageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return ExitOK
+	case errors.As(err, &exitErr):
+		return exitErr.Code
+	case usageShown:
+		// the flag set has already explained the problem
+		return ExitUsage
+	case errors.As(err, &usageErr):
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return ExitUsage
+	}
+	if s, ok := status.FromError(err); ok {
+		if s.Code() == codes.Unavailable {
+			fmt.Fprintf(os.Stderr, "error: can't reach the daemon at %s, is it running? (%s)\n", address, s.Message())
+			return ExitUnreachable
+		}
+		fmt.Fprintln(os.Stderr, "error:", s.Message())
+		return ExitFailure
+	}
+	fmt.Fprintln(os.Stderr, "error:", err)
+	return ExitFailure
+}
+
+func printHelp(global *flag.FlagSet) {
+	out := global.Output()
+	fmt.Fprintln(out, "usage: gopm [global flags] <command> [flags] [arguments...]")
+	fmt.Fprintln(out, "\ncommands:")
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	for _, c := range commands {
+		if c.Hidden {
+			continue
+		}
+		name := c.Name
+		if len(c.Aliases) > 0 {
+			name += " (" + strings.Join(c.Aliases, ", ") + ")"
+		}
+		fmt.Fprintf(w, "  %s\t%s\n", name, c.Summary)
+	}
+	w.Flush()
+	fmt.Fprintln(out, "\nglobal flags:")
+	global.PrintDefaults()
+	fmt.Fprintln(out, "\nRun gopm help <command> for the flags of a command.")
+}
+
+// runHelp shows the help of gopm or of a command.
+func runHelp(args []string) error {
+	fs := commandFlagSet("help")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		helpFlags.SetOutput(os.Stdout)
+		printHelp(helpFlags)
+		return nil
+	}
+	c := findCommand(fs.Arg(0))
+	if c == nil || c.Hidden {
+		return fmt.Errorf("unknown command %q", fs.Arg(0))
+	}
+	fs = c.flagSet()
+	fs.SetOutput(os.Stdout)
+	fs.Usage()
+	return nil
+}
+
+func RunVersion(args []string) error {
+	fs := commandFlagSet("version")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	version := Version
+	if info, ok := debug.ReadBuildInfo(); ok && version == "" {
+		version = info.Main.Version
+	}
+	if version == "" {
+		version = "(devel)"
+	}
+	fmt.Printf("gopm %s %s %s/%s\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
+
+// RunConfig runs the config subcommands. `config validate` checks a config
+// file the way `apply` would, without a daemon.
+func RunConfig(args []string) error {
+	if len(args) == 0 || args[0] != "validate" {
+		if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+			fs := commandFlagSet("config")
+			return fs.Parse(args)
+		}
+		return UsageError{"gopm config validate [flags]"}
+	}
+	var file string
+	fs := validateFlags(&file)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return UsageError{"gopm config validate [flags]"}
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %v", err)
+	}
+	req := &pb.ApplyRequest{}
+	if err := protojson.Unmarshal(data, req); err != nil {
+		return fmt.Errorf("failed to parse config %s: %v", file, err)
+	}
+	namespace := CurrentNamespace()
+	specs, jobs, err := server.ParseConfig(req, namespace)
+	if err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	external, err := process.Validate(specs, jobs)
+	if err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	for _, dep := range external {
+		fmt.Printf("note: %s isn't defined in %s and has to exist in the daemon\n", shortName(dep.Name, namespace), file)
+	}
+	fmt.Printf("%s is valid: %d process(es) and %d job(s)\n", file, len(specs), len(jobs))
+	return nil
+}
+
+// validateFlags returns the flag set of config validate, which sets file.
+func validateFlags(file *string) *flag.FlagSet {
+	fs := commandFlagSet("config")
+	fs.StringVar(file, "f", "gopm.json", "config file to check")
+	return fs
+}
@@ -0,0 +1,403 @@
+package utils
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	pb "github.com/brianykl/gopm/proto"
+)
+
+// completionGlobalFlags are the flags given before the command, in the
+// form of commandFlags.
+var completionGlobalFlags = flagNames(globalFlagSet())
+
+// completionValues are the fixed choices of flags that take one.
+var completionValues = map[string][]string{
+	"output":       {"json", "yaml", "table", "wide", "template="},
+	"o":            {"json", "yaml", "table", "wide", "template="},
+	"auto-restart": {"never", "always", "on-failure"},
+	"concurrency":  {"allow", "forbid", "replace"},
+	"ionice":       {"realtime", "best-effort", "idle"},
+	"from":         {"procfile", "pm2", "supervisord"},
+}
+
+var completionSignals = []string{"HUP", "INT", "QUIT", "KILL", "USR1", "USR2", "TERM", "CONT", "STOP", "WINCH"}
+
+const bashCompletion = `# bash completion for gopm
+_gopm() {
+    local cur words cword
+    if declare -F _get_comp_words_by_ref >/dev/null; then
+        _get_comp_words_by_ref -n =: cur words cword
+    else
+        words=("${COMP_WORDS[@]}")
+        cword=$COMP_CWORD
+        cur=${COMP_WORDS[COMP_CWORD]}
+    fi
+    local IFS=$'\n'
+    COMPREPLY=($(gopm __complete "${words[@]:1:cword}" 2>/dev/null))
+    if declare -F __ltrim_colon_completions >/dev/null; then
+        __ltrim_colon_completions "$cur"
+    fi
+}
+complete -o default -F _gopm gopm
+`
+
+const zshCompletion = `#compdef gopm
+# zsh completion for gopm
+_gopm() {
+    local -a candidates
+    candidates=("${(@f)$(gopm __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    if (( ${#candidates} )) && [[ -n ${candidates[1]} ]]; then
+        compadd -Q -S '' -a candidates
+    else
+        _files
+    fi
+}
+if [ "$funcstack[1]" = "_gopm" ]; then
+    _gopm "$@"
+else
+    compdef _gopm gopm
+fi
+`
+
+const fishCompletion = `# fish completion for gopm
+function __gopm_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    gopm __complete $tokens[2..-1] 2>/dev/null
+end
+complete -c gopm -f -a '(__gopm_complete)'
+`
+
+// RunCompletion prints the completion script for a shell.
+func RunCompletion(args []string) error {
+	fs := commandFlagSet("completion")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) != 1 {
+		return UsageError{"gopm completion <bash|zsh|fish>"}
+	}
+	switch args[0] {
+	case "bash":
+		fmt.Print(bashCompletion)
+	case "zsh":
+		fmt.Print(zshCompletion)
+	case "fish":
+		fmt.Print(fishCompletion)
+	default:
+		return fmt.Errorf("unsupported shell %q (bash|zsh|fish)", args[0])
+	}
+	return nil
+}
+
+// RunComplete prints the candidates for the last of args, the words typed
+// after "gopm", one per line. The scripts from RunCompletion call it.
+// Process names, labels and namespaces are asked from the daemon; when it
+// can't be reached they are simply left out.
+func RunComplete(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	cur, words := args[len(args)-1], args[:len(args)-1]
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	d := &completer{client: client, ctx: ctx, namespace: CurrentNamespace()}
+
+	// global flags come before the command
+	for len(words) > 0 && strings.HasPrefix(words[0], "-") {
+		if name := flagName(words[0]); takesValue(completionGlobalFlags, name) && !strings.Contains(words[0], "=") {
+			if len(words) == 1 {
+				printCandidates(d.flagValues(name, cur), cur)
+				return nil
+			}
+			words = words[1:]
+		}
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		if name, value, ok := strings.Cut(cur, "="); ok && strings.HasPrefix(cur, "-") {
+			values := d.flagValues(flagName(name), value)
+			for i, v := range values {
+				values[i] = name + "=" + v
+			}
+			printCandidates(values, name+"="+value)
+			return nil
+		}
+		if strings.HasPrefix(cur, "-") {
+			var candidates []string
+			for _, f := range completionGlobalFlags {
+				if name, _ := strings.CutSuffix(f, "="); len(name) > 1 {
+					candidates = append(candidates, "--"+name)
+				}
+			}
+			printCandidates(candidates, cur)
+			return nil
+		}
+		printCandidates(commandNames(), cur)
+		return nil
+	}
+
+	command, words := words[0], words[1:]
+	c := findCommand(command)
+	if c == nil || c.Hidden {
+		return nil
+	}
+	// every command also takes --output
+	flags := append(commandFlags(c), "output=", "o=")
+
+	// the value of a flag
+	if len(words) > 0 && strings.HasPrefix(words[len(words)-1], "-") && !strings.Contains(words[len(words)-1], "=") {
+		name := flagName(words[len(words)-1])
+		if takesValue(flags, name) {
+			printCandidates(d.flagValues(name, cur), cur)
+			return nil
+		}
+	}
+	if name, value, ok := strings.Cut(cur, "="); ok && strings.HasPrefix(cur, "-") {
+		values := d.flagValues(flagName(name), value)
+		for i, v := range values {
+			values[i] = name + "=" + v
+		}
+		printCandidates(values, cur)
+		return nil
+	}
+	if strings.HasPrefix(cur, "-") {
+		var candidates []string
+		for _, f := range flags {
+			name, _ := strings.CutSuffix(f, "=")
+			if len(name) == 1 {
+				candidates = append(candidates, "-"+name)
+			} else {
+				candidates = append(candidates, "--"+name)
+			}
+		}
+		printCandidates(candidates, cur)
+		return nil
+	}
+
+	// positional arguments
+	position := 0
+	for i := 0; i < len(words); i++ {
+		if !strings.HasPrefix(words[i], "-") {
+			position++
+		} else if !strings.Contains(words[i], "=") && takesValue(flags, flagName(words[i])) {
+			i++
+		}
+	}
+	switch {
+	case command == "help" && position == 0:
+		printCandidates(commandNames(), cur)
+	case len(c.Subcommands) > 0 && position == 0:
+		printCandidates(c.Subcommands, cur)
+	case command == "context" && position == 1:
+		printCandidates(contextNames(), cur)
+	case command == "completion" && position == 0:
+		printCandidates([]string{"bash", "zsh", "fish"}, cur)
+	case command == "namespace" && position == 0:
+		printCandidates(d.namespaces(), cur)
+	case command == "scale" && position == 0:
+		printCandidates(d.processNames(true), cur)
+	case command == "signal" && position == 1:
+		printCandidates(completionSignals, cur)
+	case position == 0:
+		switch command {
+		case "stop", "restart", "signal", "list", "status", "describe", "log", "attach", "send", "events", "remove":
+			printCandidates(d.processNames(false), cur)
+		}
+	}
+	return nil
+}
+
+// commandNames returns the names and aliases of the visible commands.
+func commandNames() []string {
+	var names []string
+	for _, c := range commands {
+		if !c.Hidden {
+			names = append(names, c.Name)
+			names = append(names, c.Aliases...)
+		}
+	}
+	return names
+}
+
+// contextNames returns the names of the contexts and host groups.
+func contextNames() []string {
+	config, _ := LoadClientConfig()
+	var names []string
+	for name := range config.Contexts {
+		names = append(names, name)
+	}
+	for name := range config.HostGroups {
+		names = append(names, name)
+	}
+	return names
+}
+
+// commandFlags lists the flags of c and of its subcommands, a trailing "="
+// marking those that take a value.
+func commandFlags(c *Command) []string {
+	sets := []*flag.FlagSet{c.flagSet()}
+	for _, sub := range c.Subcommands {
+		if flags := c.SubcommandFlags[sub]; flags != nil {
+			sets = append(sets, flags())
+		}
+	}
+	return flagNames(sets...)
+}
+
+func flagNames(sets ...*flag.FlagSet) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, fs := range sets {
+		fs.VisitAll(func(f *flag.Flag) {
+			if seen[f.Name] {
+				return
+			}
+			seen[f.Name] = true
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+				names = append(names, f.Name)
+			} else {
+				names = append(names, f.Name+"=")
+			}
+		})
+	}
+	return names
+}
+
+func flagName(arg string) string {
+	name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+	return name
+}
+
+func takesValue(flags []string, name string) bool {
+	for _, f := range flags {
+		if f == name+"=" {
+			return true
+		}
+	}
+	return false
+}
+
+func printCandidates(candidates []string, prefix string) {
+	sort.Strings(candidates)
+	for i, c := range candidates {
+		if strings.HasPrefix(c, prefix) && (i == 0 || c != candidates[i-1]) {
+			fmt.Println(c)
+		}
+	}
+}
+
+// completer looks up dynamic candidates from the daemon.
+type completer struct {
+	client    pb.ProcessManagerClient
+	ctx       context.Context
+	namespace string
+	list      *pb.ListResponse
+}
+
+func (d *completer) processes(allNamespaces bool) *pb.ListResponse {
+	if allNamespaces {
+		res, err := d.client.ListProcess(d.ctx, &pb.ListRequest{AllNamespaces: true})
+		if err != nil {
+			return &pb.ListResponse{}
+		}
+		return res
+	}
+	if d.list == nil {
+		res, err := d.client.ListProcess(d.ctx, &pb.ListRequest{})
+		if err != nil {
+			res = &pb.ListResponse{}
+		}
+		d.list = res
+	}
+	return d.list
+}
+
+// processNames returns the names of processes, their groups, jobs and
+// tasks, or only the groups.
+func (d *completer) processNames(groupsOnly bool) []string {
+	res := d.processes(false)
+	var names []string
+	for _, p := range res.Processes {
+		if !groupsOnly {
+			names = append(names, shortName(p.Name, d.namespace))
+		}
+		names = append(names, shortName(groupName(p.Name), d.namespace))
+	}
+	if groupsOnly {
+		return names
+	}
+	for _, j := range res.Jobs {
+		names = append(names, shortName(j.Spec.Name, d.namespace))
+	}
+	for _, t := range res.Tasks {
+		names = append(names, shortName(t.Name, d.namespace))
+	}
+	return names
+}
+
+func (d *completer) namespaces() []string {
+	names := []string{"default", d.namespace}
+	for _, p := range d.processes(true).Processes {
+		if namespace, _, ok := strings.Cut(p.Name, "/"); ok {
+			names = append(names, namespace)
+		}
+	}
+	return names
+}
+
+// labels returns the key=value pairs in use, each following the terms of a
+// selector typed so far.
+func (d *completer) labels(typed string) []string {
+	prefix := ""
+	if i := strings.LastIndex(typed, ","); i >= 0 {
+		prefix = typed[:i+1]
+	}
+	used := make(map[string]bool)
+	for _, term := range strings.Split(prefix, ",") {
+		key := strings.TrimPrefix(term, "!")
+		if i := strings.IndexAny(key, "!="); i >= 0 {
+			key = key[:i]
+		}
+		used[key] = true
+	}
+	var labels []string
+	for _, p := range d.processes(false).Processes {
+		for key, value := range p.Labels {
+			if !used[key] {
+				labels = append(labels, prefix+key+"="+value)
+			}
+		}
+	}
+	return labels
+}
+
+func (d *completer) flagValues(name, typed string) []string {
+	switch name {
+	case "l":
+		return d.labels(typed)
+	case "depends-on":
+		return d.processNames(true)
+	case "namespace":
+		return d.namespaces()
+	case "context":
+		return contextNames()
+	case "hosts":
+		prefix := typed[:strings.LastIndex(typed, ",")+1]
+		used := strings.Split(prefix, ",")
+		var hosts []string
+		for _, name := range contextNames() {
+			if !slices.Contains(used, name) {
+				hosts = append(hosts, prefix+name)
+			}
+		}
+		return hosts
+	}
+	return append([]string(nil), completionValues[name]...)
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCommandFlags(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"start", []string{"auto-restart=", "tty", "nice=", "output="}},
+		{"attach", []string{"detach-keys="}},
+		{"init", []string{"listen=", "resurrect"}},
+		{"startup", []string{"user", "dry-run", "state="}},
+		{"context", []string{"address=", "tls", "token-file="}},
+		{"config", []string{"f="}},
+		{"scale", []string{"output=", "o="}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			got := commandFlags(findCommand(tt.command))
+			for _, flag := range tt.want {
+				if !slices.Contains(got, flag) {
+					t.Errorf("flags = %q, missing %q", got, flag)
+				}
+			}
+		})
+	}
+
+	// the flags are found without running the commands, which would need
+	// a daemon
+	for _, c := range commands {
+		commandFlags(c)
+	}
+}
@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/brianykl/gopm/internal/process"
+	"github.com/brianykl/gopm/internal/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ClientConfig holds the CLI's settings. It lives in gopm/config.json
+// under the user's config directory.
+type ClientConfig struct {
+	// Namespace is the current namespace when no context is in use.
+	Namespace      string             `json:"namespace,omitempty"`
+	CurrentContext string             `json:"currentContext,omitempty"`
+	Contexts       map[string]Context `json:"contexts,omitempty"`
+	// HostGroups name lists of contexts or addresses for --hosts.
+	HostGroups map[string][]string `json:"hostGroups,omitempty"`
+}
+
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gopm", "config.json"), nil
+}
+
+// LoadClientConfig reads the client config, which may not exist yet.
+func LoadClientConfig() (ClientConfig, error) {
+	var config ClientConfig
+	path, err := configPath()
+	if err != nil {
+		return config, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("%s: %v", path, err)
+	}
+	return config, nil
+}
+
+func SaveClientConfig(config ClientConfig) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	// contexts may hold tokens
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// CurrentNamespace is the namespace commands act in: the one given with
+// --namespace, else GOPM_NAMESPACE if set, else that of the current
+// context, else the one in the client config, else the default namespace.
+func CurrentNamespace() string {
+	if namespaceFlag != "" {
+		return namespaceFlag
+	}
+	if namespace := os.Getenv("GOPM_NAMESPACE"); namespace != "" {
+		return namespace
+	}
+	if name, c, err := currentContext(); err == nil && name != "" {
+		if c.Namespace != "" {
+			return c.Namespace
+		}
+		return process.DefaultNamespace
+	}
+	if config, err := LoadClientConfig(); err == nil && config.Namespace != "" {
+		return config.Namespace
+	}
+	return process.DefaultNamespace
+}
+
+// NamespaceDialOptions send namespace to the daemon with every call.
+func NamespaceDialOptions(namespace string) []grpc.DialOption {
+	withNamespace := func(ctx context.Context) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, server.NamespaceMetadataKey, namespace)
+	}
+	return []grpc.DialOption{
+		grpc.WithUnaryInterceptor(func(ctx context.Context, method string, req, reply any,
+			cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(withNamespace(ctx), method, req, reply, cc, opts...)
+		}),
+		grpc.WithStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+			method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return streamer(withNamespace(ctx), desc, cc, method, opts...)
+		}),
+	}
+}
+
+// RunNamespace prints the current namespace, or makes another one current,
+// in the current context if there is one.
+func RunNamespace(args []string) error {
+	fs := commandFlagSet("namespace")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) == 0 {
+		fmt.Println(CurrentNamespace())
+		return nil
+	}
+	if len(args) != 1 {
+		return UsageError{"gopm namespace [name]"}
+	}
+	namespace := args[0]
+	if strings.ContainsAny(namespace, "/*?[") {
+		return fmt.Errorf("invalid namespace %q", namespace)
+	}
+
+	config, err := LoadClientConfig()
+	if err != nil {
+		return err
+	}
+	name, c, err := currentContext()
+	if err != nil {
+		return err
+	}
+	if name != "" {
+		c.Namespace = namespace
+		config.Contexts[name] = c
+	} else {
+		config.Namespace = namespace
+	}
+	if err := SaveClientConfig(config); err != nil {
+		return err
+	}
+	fmt.Printf("switched to namespace %s\n", namespace)
+	if env := os.Getenv("GOPM_NAMESPACE"); env != "" && env != namespace {
+		fmt.Printf("note: GOPM_NAMESPACE=%s takes precedence in this shell\n", env)
+	}
+	return nil
+}
+
+// shortName strips namespace, the current one of the command, from a name
+// for display.
+func shortName(name, namespace string) string {
+	return strings.TrimPrefix(name, namespace+"/")
+}
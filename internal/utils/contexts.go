@@ -0,0 +1,417 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Context is a daemon the CLI can talk to, with what it takes to reach it.
+type Context struct {
+	Address   string `json:"address,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// TLS connects with TLS, verified against the system roots unless CA
+	// is set. CA, Cert or Key imply it.
+	TLS        bool   `json:"tls,omitempty"`
+	CA         string `json:"ca,omitempty"`
+	Cert       string `json:"cert,omitempty"`
+	Key        string `json:"key,omitempty"`
+	ServerName string `json:"serverName,omitempty"`
+	// Token, or the contents of TokenFile, is sent as a bearer token.
+	Token     string `json:"token,omitempty"`
+	TokenFile string `json:"tokenFile,omitempty"`
+}
+
+func (c Context) usesTLS() bool {
+	return c.TLS || c.CA != "" || c.Cert != "" || c.Key != ""
+}
+
+// contextFlag is the context given with --context.
+var contextFlag string
+
+// currentContext returns the context commands talk to: the one given with
+// --context, else GOPM_CONTEXT if set, else the current one of the client
+// config. Without any, the name is empty and the context is the local
+// daemon's.
+func currentContext() (string, Context, error) {
+	config, err := LoadClientConfig()
+	if err != nil {
+		return "", Context{}, err
+	}
+	name := contextFlag
+	if name == "" {
+		name = os.Getenv("GOPM_CONTEXT")
+	}
+	if name == "" {
+		name = config.CurrentContext
+	}
+	if name == "" {
+		return "", Context{}, nil
+	}
+	c, ok := config.Contexts[name]
+	if !ok {
+		return name, Context{}, fmt.Errorf("unknown context %q, see gopm context list", name)
+	}
+	return name, c, nil
+}
+
+// daemonAddress is where the daemon of c is: the address given with
+// --address, else GOPM_ADDRESS if set, else that of c, else the local one.
+func daemonAddress(c Context) string {
+	switch {
+	case address != "":
+		return address
+	case os.Getenv("GOPM_ADDRESS") != "":
+		return os.Getenv("GOPM_ADDRESS")
+	case c.Address != "":
+		return c.Address
+	}
+	return defaultAddress
+}
+
+// dial connects to the daemon at address with the credentials of c, acting
+// in namespace.
+func dial(address string, c Context, namespace string) (*grpc.ClientConn, error) {
+	opts := NamespaceDialOptions(namespace)
+	creds := insecure.NewCredentials()
+	if c.usesTLS() {
+		config := &tls.Config{ServerName: c.ServerName, MinVersion: tls.VersionTLS12}
+		if c.CA != "" {
+			pem, err := os.ReadFile(c.CA)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA: %v", err)
+			}
+			config.RootCAs = x509.NewCertPool()
+			if !config.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", c.CA)
+			}
+		}
+		if c.Cert != "" || c.Key != "" {
+			cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load client certificate: %v", err)
+			}
+			config.Certificates = []tls.Certificate{cert}
+		}
+		creds = credentials.NewTLS(config)
+	}
+	opts = append(opts, grpc.WithTransportCredentials(creds))
+
+	token := c.Token
+	if c.TokenFile != "" {
+		data, err := os.ReadFile(c.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token: %v", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(bearerToken(token)))
+	}
+	return grpc.NewClient(address, opts...)
+}
+
+// bearerToken authenticates calls with a token. It is also sent without
+// TLS, e.g. over a unix socket or an SSH tunnel.
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t bearerToken) RequireTransportSecurity() bool {
+	return false
+}
+
+// RunContext lists, switches and edits the contexts of the client config.
+func RunContext(args []string) error {
+	fs := commandFlagSet("context")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) == 0 {
+		args = []string{"current"}
+	}
+	if args[0] != "set" {
+		// only set has flags, but the others still answer -h
+		sub := commandFlagSet("context")
+		if err := sub.Parse(args[1:]); err != nil {
+			return err
+		}
+		args = append([]string{args[0]}, sub.Args()...)
+	}
+	switch args[0] {
+	case "current":
+		name, _, err := currentContext()
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			fmt.Printf("no context, using %s\n", daemonAddress(Context{}))
+			return nil
+		}
+		fmt.Println(name)
+		return nil
+	case "list":
+		return listContexts()
+	case "use":
+		return useContext(args[1:])
+	case "set":
+		return setContext(args[1:])
+	case "remove":
+		return removeContext(args[1:])
+	case "group":
+		return setHostGroup(args[1:])
+	}
+	return UsageError{"gopm context <current|list|use|set|group|remove> ..."}
+}
+
+func listContexts() error {
+	config, err := LoadClientConfig()
+	if err != nil {
+		return err
+	}
+	current, _, _ := currentContext()
+	names := make([]string, 0, len(config.Contexts))
+	for name := range config.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := newTable("CURRENT", "NAME", "ADDRESS", "NAMESPACE", "AUTH")
+	for _, name := range names {
+		c := config.Contexts[name]
+		marker := ""
+		if name == current {
+			marker = "*"
+		}
+		var auth []string
+		if c.usesTLS() {
+			auth = append(auth, "tls")
+		}
+		if c.Cert != "" {
+			auth = append(auth, "client-cert")
+		}
+		if c.Token != "" || c.TokenFile != "" {
+			auth = append(auth, "token")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", marker, name, orDefault(c.Address, defaultAddress),
+			orDefault(c.Namespace, "-"), orDefault(strings.Join(auth, ","), "-"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(config.HostGroups) == 0 {
+		return nil
+	}
+	groups := make([]string, 0, len(config.HostGroups))
+	for name := range config.HostGroups {
+		groups = append(groups, name)
+	}
+	sort.Strings(groups)
+	fmt.Println()
+	w = newTable("GROUP", "HOSTS")
+	for _, name := range groups {
+		fmt.Fprintf(w, "%s\t%s\n", name, strings.Join(config.HostGroups[name], ","))
+	}
+	return w.Flush()
+}
+
+func orDefault(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+func useContext(args []string) error {
+	if len(args) != 1 {
+		return UsageError{"gopm context use <name>"}
+	}
+	config, err := LoadClientConfig()
+	if err != nil {
+		return err
+	}
+	if _, ok := config.Contexts[args[0]]; !ok {
+		return fmt.Errorf("unknown context %q, see gopm context list", args[0])
+	}
+	config.CurrentContext = args[0]
+	if err := SaveClientConfig(config); err != nil {
+		return err
+	}
+	fmt.Printf("switched to context %s\n", args[0])
+	if env := os.Getenv("GOPM_CONTEXT"); env != "" && env != args[0] {
+		fmt.Printf("note: GOPM_CONTEXT=%s takes precedence in this shell\n", env)
+	}
+	return nil
+}
+
+// setContextFlags returns the flag set of context set, which fills in c.
+func setContextFlags(c *Context) *flag.FlagSet {
+	fs := commandFlagSet("context")
+	fs.StringVar(&c.Address, "address", "", "address of the daemon, host:port or unix:/path")
+	fs.StringVar(&c.Namespace, "namespace", "", "namespace to act in")
+	fs.BoolVar(&c.TLS, "tls", false, "connect with TLS")
+	fs.StringVar(&c.CA, "ca", "", "CA certificate that signed the daemon's (PEM)")
+	fs.StringVar(&c.Cert, "cert", "", "client certificate (PEM)")
+	fs.StringVar(&c.Key, "key", "", "private key of --cert (PEM)")
+	fs.StringVar(&c.ServerName, "server-name", "", "name to verify the daemon's certificate against")
+	fs.StringVar(&c.Token, "token", "", "token to authenticate with")
+	fs.StringVar(&c.TokenFile, "token-file", "", "file holding the token to authenticate with")
+	return fs
+}
+
+// setContext creates a context or changes the given settings of one.
+func setContext(args []string) error {
+	var c Context
+	fs := setContextFlags(&c)
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		// for -h
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		return UsageError{"gopm context set <name> [flags]"}
+	}
+	name := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return UsageError{"gopm context set <name> [flags]"}
+	}
+
+	// files are read from wherever gopm runs later
+	for _, path := range []*string{&c.CA, &c.Cert, &c.Key, &c.TokenFile} {
+		if *path != "" {
+			abs, err := filepath.Abs(*path)
+			if err != nil {
+				return err
+			}
+			*path = abs
+		}
+	}
+
+	config, err := LoadClientConfig()
+	if err != nil {
+		return err
+	}
+	if config.Contexts == nil {
+		config.Contexts = make(map[string]Context)
+	}
+	existing, ok := config.Contexts[name]
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "address":
+			existing.Address = c.Address
+		case "namespace":
+			existing.Namespace = c.Namespace
+		case "tls":
+			existing.TLS = c.TLS
+		case "ca":
+			existing.CA = c.CA
+		case "cert":
+			existing.Cert = c.Cert
+		case "key":
+			existing.Key = c.Key
+		case "server-name":
+			existing.ServerName = c.ServerName
+		case "token":
+			existing.Token = c.Token
+		case "token-file":
+			existing.TokenFile = c.TokenFile
+		}
+	})
+	config.Contexts[name] = existing
+	if err := SaveClientConfig(config); err != nil {
+		return err
+	}
+	if ok {
+		fmt.Printf("context %s updated\n", name)
+	} else {
+		fmt.Printf("context %s created, switch to it with gopm context use %s\n", name, name)
+	}
+	return nil
+}
+
+// removeContext removes a context or a host group.
+func removeContext(args []string) error {
+	if len(args) != 1 {
+		return UsageError{"gopm context remove <name>"}
+	}
+	config, err := LoadClientConfig()
+	if err != nil {
+		return err
+	}
+	name := args[0]
+	if _, ok := config.HostGroups[name]; ok {
+		delete(config.HostGroups, name)
+		if err := SaveClientConfig(config); err != nil {
+			return err
+		}
+		fmt.Printf("host group %s removed\n", name)
+		return nil
+	}
+	if _, ok := config.Contexts[name]; !ok {
+		return fmt.Errorf("unknown context %q", name)
+	}
+	delete(config.Contexts, name)
+	if config.CurrentContext == name {
+		config.CurrentContext = ""
+	}
+	if err := SaveClientConfig(config); err != nil {
+		return err
+	}
+	fmt.Printf("context %s removed\n", name)
+	return nil
+}
+
+// setHostGroup names a list of contexts or addresses for --hosts.
+func setHostGroup(args []string) error {
+	if len(args) != 2 {
+		return UsageError{"gopm context group <name> <host,...>"}
+	}
+	name := args[0]
+	var hosts []string
+	for _, h := range strings.Split(args[1], ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	if len(hosts) == 0 {
+		return UsageError{"gopm context group <name> <host,...>"}
+	}
+
+	config, err := LoadClientConfig()
+	if err != nil {
+		return err
+	}
+	if _, ok := config.Contexts[name]; ok {
+		return fmt.Errorf("%s is already a context", name)
+	}
+	for _, h := range hosts {
+		if _, ok := config.HostGroups[h]; ok && h != name {
+			return fmt.Errorf("%s is a host group, groups can't contain groups", h)
+		}
+	}
+	if config.HostGroups == nil {
+		config.HostGroups = make(map[string][]string)
+	}
+	config.HostGroups[name] = hosts
+	if err := SaveClientConfig(config); err != nil {
+		return err
+	}
+	fmt.Printf("host group %s set to %s\n", name, strings.Join(hosts, ","))
+	return nil
+}
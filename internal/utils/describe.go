@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	pb "github.com/brianykl/gopm/proto"
+)
+
+// describeFlags returns the flag set of describe, which sets lines.
+func describeFlags(lines *int) *flag.FlagSet {
+	fs := newFlagSet("describe")
+	fs.IntVar(lines, "lines", 20, "lines of output to show, -1 for all")
+	return fs
+}
+
+// RunDescribe prints everything the daemon knows about one process.
+func RunDescribe(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
+	var lines int
+	fs := describeFlags(&lines)
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return UsageError{"gopm describe <flag> <name>"}
+	}
+
+	req := &pb.DescribeRequest{Name: fs.Arg(0), LogLines: int32(lines)}
+	d, err := client.DescribeProcess(ctx, req)
+	if err != nil {
+		return err
+	}
+	// the daemon takes 0 to mean its default
+	if lines == 0 {
+		d.Logs = nil
+	}
+	if machineOutput() {
+		return printMessage(d)
+	}
+	printDescription(d, CurrentNamespace())
+	return nil
+}
+
+func printDescription(d *pb.ProcessDescription, namespace string) {
+	spec, info := d.Spec, d.Info
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	field := func(name, format string, args ...any) {
+		fmt.Fprintf(w, "%s:\t%s\n", name, fmt.Sprintf(format, args...))
+	}
+
+	namespace, name, _ := strings.Cut(info.Name, "/")
+	field("Name", "%s", name)
+	field("Namespace", "%s", namespace)
+	status := info.Status
+	if spec.HealthCheck != nil && info.Status == "running" {
+		if d.Healthy {
+			status += " (healthy)"
+		} else {
+			status += " (not healthy)"
+		}
+	}
+	field("Status", "%s", status)
+	if info.Pid != 0 {
+		field("PID", "%d", info.Pid)
+	}
+	if d.StartedAt != nil {
+		started := d.StartedAt.AsTime()
+		if info.Status == "running" {
+			field("Started", "%s (up %s)", started.Local().Format(time.DateTime), time.Since(started).Round(time.Second))
+		} else {
+			field("Started", "%s", started.Local().Format(time.DateTime))
+		}
+	}
+	restarts := fmt.Sprint(info.Restarts)
+	if info.RestartReason != "" {
+		restarts += fmt.Sprintf(" (last: %s)", info.RestartReason)
+	}
+	field("Restarts", "%s", restarts)
+
+	field("Command", "%s", strings.Join(append([]string{spec.Command}, spec.Args...), " "))
+	if spec.Cwd != "" {
+		field("Working dir", "%s", spec.Cwd)
+	}
+	policy := spec.AutoRestart
+	if policy == "" {
+		policy = "never"
+	}
+	field("Restart policy", "%s", policy)
+	if spec.Instances > 0 {
+		field("Instances", "%d", spec.Instances)
+	}
+	if spec.BasePort > 0 {
+		field("Base port", "%d", spec.BasePort)
+	}
+	if len(spec.Labels) > 0 {
+		field("Labels", "%s", formatLabels(spec.Labels))
+	}
+	if len(spec.DependsOn) > 0 {
+		deps := make([]string, len(spec.DependsOn))
+		for i, dep := range spec.DependsOn {
+			deps[i] = shortName(dep.Name, namespace)
+			if dep.Condition != "" {
+				deps[i] += ":" + dep.Condition
+			}
+		}
+		field("Depends on", "%s", strings.Join(deps, ", "))
+	}
+	if hc := spec.HealthCheck; hc != nil {
+		check := hc.Command
+		if hc.Interval != "" {
+			check += " every " + hc.Interval
+		}
+		if hc.Timeout != "" {
+			check += ", timeout " + hc.Timeout
+		}
+		if hc.Retries > 0 {
+			check += fmt.Sprintf(", %d retries", hc.Retries)
+		}
+		field("Health check", "%s", check)
+	}
+	if wt := spec.Watch; wt != nil {
+		watch := strings.Join(wt.Paths, ", ")
+		if len(wt.Ignore) > 0 {
+			watch += " (ignoring " + strings.Join(wt.Ignore, ", ") + ")"
+		}
+		field("Watch", "%s", watch)
+	}
+	if spec.Limits != nil {
+		field("Limits", "%s", formatLimits(spec.Limits))
+	}
+	if spec.MaxMemory > 0 || spec.MaxCpu > 0 {
+		var thresholds []string
+		if spec.MaxMemory > 0 {
+			thresholds = append(thresholds, "memory="+formatBytes(spec.MaxMemory))
+		}
+		if spec.MaxCpu > 0 {
+			thresholds = append(thresholds, fmt.Sprintf("cpu=%g%%", spec.MaxCpu))
+		}
+		if spec.ThresholdDuration != "" {
+			thresholds = append(thresholds, "for "+spec.ThresholdDuration)
+		}
+		field("Restart above", "%s", strings.Join(thresholds, " "))
+	}
+	if info.Scheduling != nil {
+		field("Scheduling", "%s", formatScheduling(info.Scheduling))
+	} else if spec.Scheduling != nil {
+		field("Scheduling", "%s", formatScheduling(spec.Scheduling))
+	}
+	if spec.Tty || spec.Stdin {
+		var input []string
+		if spec.Tty {
+			input = append(input, "tty")
+		}
+		if spec.Stdin {
+			input = append(input, "stdin")
+		}
+		field("Input", "%s", strings.Join(input, ", "))
+	}
+	if info.Status == "running" {
+		field("Usage", "cpu %.1f%%, memory %s", info.CpuPercent, formatBytes(info.MemoryBytes))
+	}
+	if u := info.Usage; u != nil {
+		field("Cgroup", "%s memory=%s cpu=%s pids=%d", u.Path, formatBytes(u.MemoryCurrent),
+			time.Duration(u.CpuUsageUsec)*time.Microsecond, u.PidsCurrent)
+	}
+	w.Flush()
+
+	if len(spec.Env) > 0 {
+		fmt.Println("Environment:")
+		keys := make([]string, 0, len(spec.Env))
+		for key := range spec.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Printf("  %s=%s\n", key, spec.Env[key])
+		}
+	}
+	if len(d.Exits) > 0 {
+		fmt.Println("Recent exits:")
+		for _, exit := range d.Exits {
+			detail := fmt.Sprintf("exit code %d", exit.Code)
+			if exit.Signal != "" {
+				detail = "signal " + exit.Signal
+			}
+			fmt.Printf("  %s  %s, %s\n", exit.Time.AsTime().Local().Format(time.DateTime), exit.Reason, detail)
+		}
+	}
+	if len(d.HealthChecks) > 0 {
+		fmt.Println("Recent health checks:")
+		for _, check := range d.HealthChecks {
+			result := "passed"
+			if !check.Healthy {
+				result = "failed: " + check.Message
+			}
+			fmt.Printf("  %s  %s\n", check.Time.AsTime().Local().Format(time.DateTime), result)
+		}
+	}
+	if len(d.Logs) > 0 {
+		fmt.Println("Logs:")
+		for _, line := range d.Logs {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+}
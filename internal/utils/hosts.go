@@ -0,0 +1,336 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/brianykl/gopm/internal/process"
+	pb "github.com/brianykl/gopm/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// hostsFlag is the list of daemons given with --hosts.
+var hostsFlag string
+
+// fanningOut tells whether the command runs against several daemons, in
+// which case output shows the host of everything.
+func fanningOut() bool {
+	return hostsFlag != ""
+}
+
+// hostPrefix marks a line of text output with its host.
+func hostPrefix(host string) string {
+	if host == "" {
+		return ""
+	}
+	return "[" + host + "] "
+}
+
+// host is one of the daemons a command fans out over.
+type host struct {
+	name      string
+	address   string
+	context   Context
+	namespace string
+}
+
+// resolveHosts expands the comma separated hosts of --hosts. Each is a
+// host group of the client config, a context, or an address reached with
+// the credentials of the current context.
+func resolveHosts(spec string) ([]host, error) {
+	config, err := LoadClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	_, current, err := currentContext()
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []host
+	seen := make(map[string]bool)
+	var add func(name string, depth int) error
+	add = func(name string, depth int) error {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			return nil
+		}
+		if members, ok := config.HostGroups[name]; ok {
+			if depth > 0 {
+				return fmt.Errorf("host group %s contains another group", name)
+			}
+			for _, member := range members {
+				if err := add(member, depth+1); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		seen[name] = true
+		if c, ok := config.Contexts[name]; ok {
+			namespace := namespaceOverride()
+			if namespace == "" {
+				namespace = c.Namespace
+			}
+			if namespace == "" {
+				namespace = process.DefaultNamespace
+			}
+			hosts = append(hosts, host{name: name, address: orDefault(c.Address, defaultAddress), context: c, namespace: namespace})
+			return nil
+		}
+		hosts = append(hosts, host{name: name, address: name, context: current, namespace: CurrentNamespace()})
+		return nil
+	}
+	for _, name := range strings.Split(spec, ",") {
+		if err := add(name, 0); err != nil {
+			return nil, err
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts in %q", spec)
+	}
+	return hosts, nil
+}
+
+// namespaceOverride is the namespace given with --namespace or
+// GOPM_NAMESPACE, which applies to every host.
+func namespaceOverride() string {
+	if namespaceFlag != "" {
+		return namespaceFlag
+	}
+	return os.Getenv("GOPM_NAMESPACE")
+}
+
+// fanOut is a client that sends every call to several daemons
+// concurrently and merges their responses, setting the host of each
+// message. A daemon that fails is reported on stderr and left out, and
+// the command then exits non-zero.
+//
+// Only the calls of commands marked FanOut are implemented; the others
+// belong to commands that act on a single process on a single daemon.
+type fanOut struct {
+	pb.ProcessManagerClient
+	hosts   []host
+	clients []pb.ProcessManagerClient
+
+	mu     sync.Mutex
+	failed bool
+}
+
+// dialHosts connects to every host. The connections are closed by the
+// returned function.
+func dialHosts(hosts []host) (*fanOut, func(), error) {
+	f := &fanOut{hosts: hosts}
+	var conns []*grpc.ClientConn
+	closeAll := func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+	for _, h := range hosts {
+		conn, err := dial(h.address, h.context, h.namespace)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("%s: %v", h.name, err)
+		}
+		conns = append(conns, conn)
+		f.clients = append(f.clients, pb.NewProcessManagerClient(conn))
+	}
+	return f, closeAll, nil
+}
+
+// report prints the failure of one host.
+func (f *fanOut) report(h host, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failed = true
+	fmt.Fprintf(os.Stderr, "error: %s: %s\n", h.name, status.Convert(err).Message())
+}
+
+// callAll makes a call to every host concurrently.
+func callAll[T any](f *fanOut, call func(pb.ProcessManagerClient) (T, error)) ([]T, []error) {
+	results := make([]T, len(f.clients))
+	errs := make([]error, len(f.clients))
+	var wg sync.WaitGroup
+	for i, client := range f.clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = call(client)
+		}()
+	}
+	wg.Wait()
+	return results, errs
+}
+
+// setResultHosts sets the host of the results of a response. A response
+// without results becomes one, so that its message shows the host too.
+func setResultHosts(res *pb.ProcessResponse, host string) {
+	if len(res.Results) == 0 && res.Message != "" {
+		res.Results = []*pb.ProcessResult{{Success: res.Success, Message: res.Message}}
+		res.Message = ""
+	}
+	for _, r := range res.Results {
+		r.Host = host
+	}
+}
+
+// response merges the responses of a call that returns a ProcessResponse.
+// A host that fails counts as a failed result rather than an error.
+func (f *fanOut) response(call func(pb.ProcessManagerClient) (*pb.ProcessResponse, error)) (*pb.ProcessResponse, error) {
+	responses, errs := callAll(f, call)
+	merged := &pb.ProcessResponse{Success: true}
+	for i, h := range f.hosts {
+		res := responses[i]
+		if errs[i] != nil {
+			res = &pb.ProcessResponse{Message: status.Convert(errs[i]).Message()}
+		}
+		setResultHosts(res, h.name)
+		merged.Results = append(merged.Results, res.Results...)
+		merged.Success = merged.Success && res.Success
+	}
+	return merged, nil
+}
+
+func (f *fanOut) StartProcess(ctx context.Context, in *pb.StartRequest, opts ...grpc.CallOption) (*pb.ProcessResponse, error) {
+	return f.response(func(c pb.ProcessManagerClient) (*pb.ProcessResponse, error) { return c.StartProcess(ctx, in, opts...) })
+}
+
+func (f *fanOut) StopProcess(ctx context.Context, in *pb.StopRequest, opts ...grpc.CallOption) (*pb.ProcessResponse, error) {
+	return f.response(func(c pb.ProcessManagerClient) (*pb.ProcessResponse, error) { return c.StopProcess(ctx, in, opts...) })
+}
+
+func (f *fanOut) RemoveProcess(ctx context.Context, in *pb.RemoveRequest, opts ...grpc.CallOption) (*pb.ProcessResponse, error) {
+	return f.response(func(c pb.ProcessManagerClient) (*pb.ProcessResponse, error) { return c.RemoveProcess(ctx, in, opts...) })
+}
+
+func (f *fanOut) Apply(ctx context.Context, in *pb.ApplyRequest, opts ...grpc.CallOption) (*pb.ProcessResponse, error) {
+	return f.response(func(c pb.ProcessManagerClient) (*pb.ProcessResponse, error) { return c.Apply(ctx, in, opts...) })
+}
+
+func (f *fanOut) ScaleProcess(ctx context.Context, in *pb.ScaleRequest, opts ...grpc.CallOption) (*pb.ProcessResponse, error) {
+	return f.response(func(c pb.ProcessManagerClient) (*pb.ProcessResponse, error) { return c.ScaleProcess(ctx, in, opts...) })
+}
+
+func (f *fanOut) ScheduleJob(ctx context.Context, in *pb.JobSpec, opts ...grpc.CallOption) (*pb.ProcessResponse, error) {
+	return f.response(func(c pb.ProcessManagerClient) (*pb.ProcessResponse, error) { return c.ScheduleJob(ctx, in, opts...) })
+}
+
+func (f *fanOut) SignalProcess(ctx context.Context, in *pb.SignalRequest, opts ...grpc.CallOption) (*pb.ProcessResponse, error) {
+	return f.response(func(c pb.ProcessManagerClient) (*pb.ProcessResponse, error) { return c.SignalProcess(ctx, in, opts...) })
+}
+
+func (f *fanOut) ListProcess(ctx context.Context, in *pb.ListRequest, opts ...grpc.CallOption) (*pb.ListResponse, error) {
+	responses, errs := callAll(f, func(c pb.ProcessManagerClient) (*pb.ListResponse, error) { return c.ListProcess(ctx, in, opts...) })
+	merged := &pb.ListResponse{}
+	for i, h := range f.hosts {
+		if errs[i] != nil {
+			f.report(h, errs[i])
+			continue
+		}
+		for _, p := range responses[i].Processes {
+			p.Host = h.name
+		}
+		for _, j := range responses[i].Jobs {
+			j.Host = h.name
+		}
+		for _, t := range responses[i].Tasks {
+			t.Host = h.name
+		}
+		merged.Processes = append(merged.Processes, responses[i].Processes...)
+		merged.Jobs = append(merged.Jobs, responses[i].Jobs...)
+		merged.Tasks = append(merged.Tasks, responses[i].Tasks...)
+	}
+	return merged, nil
+}
+
+func (f *fanOut) StreamLogs(ctx context.Context, in *pb.LogRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[pb.LogLine], error) {
+	return mergeStreams(f, ctx, func(c pb.ProcessManagerClient) (grpc.ServerStreamingClient[pb.LogLine], error) {
+		return c.StreamLogs(ctx, in, opts...)
+	}, func(line *pb.LogLine, host string) { line.Host = host })
+}
+
+func (f *fanOut) StreamEvents(ctx context.Context, in *pb.EventRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[pb.Event], error) {
+	return mergeStreams(f, ctx, func(c pb.ProcessManagerClient) (grpc.ServerStreamingClient[pb.Event], error) {
+		return c.StreamEvents(ctx, in, opts...)
+	}, func(e *pb.Event, host string) { e.Host = host })
+}
+
+func (f *fanOut) RestartProcess(ctx context.Context, in *pb.RestartRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[pb.ProcessResponse], error) {
+	return mergeStreams(f, ctx, func(c pb.ProcessManagerClient) (grpc.ServerStreamingClient[pb.ProcessResponse], error) {
+		return c.RestartProcess(ctx, in, opts...)
+	}, setResultHosts)
+}
+
+// mergedStream interleaves the messages of several streams in the order
+// they arrive.
+type mergedStream[T any] struct {
+	// the first of the streams, for the methods other than Recv
+	grpc.ClientStream
+	messages chan *T
+}
+
+func (s *mergedStream[T]) Recv() (*T, error) {
+	m, ok := <-s.messages
+	if !ok {
+		return nil, io.EOF
+	}
+	return m, nil
+}
+
+// mergeStreams opens a stream to every host and merges them. It fails
+// only if no stream could be opened; a stream that breaks later is
+// reported and the others go on.
+func mergeStreams[T any](f *fanOut, ctx context.Context, open func(pb.ProcessManagerClient) (grpc.ServerStreamingClient[T], error),
+	setHost func(*T, string)) (grpc.ServerStreamingClient[T], error) {
+	streams, errs := callAll(f, open)
+	merged := &mergedStream[T]{messages: make(chan *T)}
+	var wg sync.WaitGroup
+	var firstErr error
+	for i, h := range f.hosts {
+		if errs[i] != nil {
+			f.report(h, errs[i])
+			firstErr = errs[i]
+			continue
+		}
+		if merged.ClientStream == nil {
+			merged.ClientStream = streams[i]
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				m, err := streams[i].Recv()
+				if err == io.EOF {
+					return
+				}
+				if err != nil {
+					if status.Code(err) != codes.Canceled {
+						f.report(h, err)
+					}
+					return
+				}
+				setHost(m, h.name)
+				select {
+				case merged.messages <- m:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	if merged.ClientStream == nil {
+		return nil, firstErr
+	}
+	go func() {
+		wg.Wait()
+		close(merged.messages)
+	}()
+	return merged, nil
+}
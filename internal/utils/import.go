@@ -0,0 +1,666 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brianykl/gopm/internal/process"
+	"github.com/brianykl/gopm/internal/server"
+	pb "github.com/brianykl/gopm/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Formats `gopm import` reads.
+const (
+	FormatProcfile    = "procfile"
+	FormatPM2         = "pm2"
+	FormatSupervisord = "supervisord"
+)
+
+// importOptions are the flags of import.
+type importOptions struct {
+	from, out string
+}
+
+func importFlags(o *importOptions) *flag.FlagSet {
+	fs := commandFlagSet("import")
+	fs.StringVar(&o.from, "from", "", "format of the file: procfile, pm2 or supervisord (default: guessed from the file name)")
+	fs.StringVar(&o.out, "out", "", "file to write the config to (default: stdout)")
+	return fs
+}
+
+// RunImport converts the process definitions of another process manager
+// to a config file for `gopm apply`. Options that have no equivalent are
+// reported on stderr.
+func RunImport(args []string) error {
+	var o importOptions
+	fs := importFlags(&o)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return UsageError{"gopm import [flags] <file>"}
+	}
+	file := fs.Arg(0)
+	if o.from == "" {
+		o.from = guessFormat(file)
+		if o.from == "" {
+			return fmt.Errorf("can't tell the format of %s, use --from procfile|pm2|supervisord", file)
+		}
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	im := &importer{dir: filepath.Dir(file)}
+	switch o.from {
+	case FormatProcfile:
+		err = im.procfile(data)
+	case FormatPM2:
+		err = im.pm2(data)
+	case FormatSupervisord:
+		err = im.supervisord(data)
+	default:
+		return fmt.Errorf("unknown format %q (procfile|pm2|supervisord)", o.from)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+
+	for _, note := range im.notes {
+		fmt.Fprintln(os.Stderr, "warning:", note)
+	}
+	// the result should apply as is; if not, say why but still write it
+	if specs, jobs, err := server.ParseConfig(&im.config, CurrentNamespace()); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: the result needs editing before it applies:", err)
+	} else if _, err := process.Validate(specs, jobs); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: the result needs editing before it applies:", err)
+	}
+
+	data, err = protojson.Marshal(&im.config)
+	if err != nil {
+		return err
+	}
+	// protojson's own indentation isn't stable
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "", "  "); err != nil {
+		return err
+	}
+	indented.WriteByte('\n')
+	if o.out == "" {
+		_, err = os.Stdout.Write(indented.Bytes())
+		return err
+	}
+	if err := os.WriteFile(o.out, indented.Bytes(), 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d process(es) to %s\n", len(im.config.Processes), o.out)
+	return nil
+}
+
+func guessFormat(file string) string {
+	base := strings.ToLower(filepath.Base(file))
+	switch {
+	case strings.HasPrefix(base, "procfile"):
+		return FormatProcfile
+	case strings.HasSuffix(base, ".json"):
+		return FormatPM2
+	case strings.HasSuffix(base, ".conf"), strings.HasSuffix(base, ".ini"):
+		return FormatSupervisord
+	}
+	return ""
+}
+
+type importer struct {
+	// dir is the directory of the imported file, which relative paths
+	// in it are relative to
+	dir    string
+	config pb.ApplyRequest
+	notes  []string
+}
+
+func (im *importer) note(format string, args ...any) {
+	im.notes = append(im.notes, fmt.Sprintf(format, args...))
+}
+
+func (im *importer) abs(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	abs, err := filepath.Abs(filepath.Join(im.dir, path))
+	if err != nil {
+		return path
+	}
+	return abs
+}
+
+var procfileLine = regexp.MustCompile(`^([A-Za-z0-9_-]+):\s*(.+)$`)
+
+// procfile converts a Procfile of Heroku or foreman. Like foreman, every
+// process gets a PORT, 5000 for the first and 100 more for each next one,
+// and the variables of a .env file next to the Procfile.
+func (im *importer) procfile(data []byte) error {
+	env, err := readDotEnv(filepath.Join(im.dir, ".env"))
+	if err != nil {
+		return err
+	}
+	if len(env) > 0 {
+		im.note("the variables of %s were added to every process", filepath.Join(im.dir, ".env"))
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := procfileLine.FindStringSubmatch(line)
+		if m == nil {
+			return fmt.Errorf("line %d: expected <name>: <command>", n)
+		}
+		im.config.Processes = append(im.config.Processes, &pb.ProcessSpec{
+			Name: m[1],
+			// Procfile commands are shell commands
+			Command:     "sh",
+			Args:        []string{"-c", m[2]},
+			AutoRestart: "always",
+			Env:         env,
+			Cwd:         im.abs("."),
+			BasePort:    int32(5000 + 100*len(im.config.Processes)),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(im.config.Processes) == 0 {
+		return fmt.Errorf("no processes found")
+	}
+	return nil
+}
+
+// readDotEnv reads the KEY=value lines of a .env file, if there is one.
+func readDotEnv(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "export "))
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.HasPrefix(line, "#") {
+			continue
+		}
+		env[strings.TrimSpace(key)] = unquote(strings.TrimSpace(value))
+	}
+	return env, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// pm2App is an app of a pm2 ecosystem file. Only the options that map to
+// gopm are decoded; the others are reported by name.
+type pm2App struct {
+	Name            string          `json:"name"`
+	Script          string          `json:"script"`
+	Args            json.RawMessage `json:"args"`
+	Interpreter     string          `json:"interpreter"`
+	InterpreterArgs json.RawMessage `json:"interpreter_args"`
+	NodeArgs        json.RawMessage `json:"node_args"`
+	Cwd             string          `json:"cwd"`
+	Env             map[string]any  `json:"env"`
+	Instances       json.RawMessage `json:"instances"`
+	ExecMode        string          `json:"exec_mode"`
+	Autorestart     *bool           `json:"autorestart"`
+	Watch           json.RawMessage `json:"watch"`
+	IgnoreWatch     json.RawMessage `json:"ignore_watch"`
+	WatchDelay      *float64        `json:"watch_delay"`
+	MaxMemory       string          `json:"max_memory_restart"`
+}
+
+var pm2Mapped = map[string]bool{
+	"name": true, "script": true, "args": true, "interpreter": true, "interpreter_args": true,
+	"node_args": true, "cwd": true, "env": true, "instances": true, "exec_mode": true,
+	"autorestart": true, "watch": true, "ignore_watch": true, "watch_delay": true,
+	"max_memory_restart": true,
+}
+
+// pm2Interpreters are the interpreters pm2 picks by the script's extension.
+var pm2Interpreters = map[string]string{
+	".js": "node", ".mjs": "node", ".cjs": "node", ".ts": "ts-node", ".coffee": "coffee",
+	".py": "python3", ".rb": "ruby", ".php": "php", ".pl": "perl", ".sh": "bash",
+}
+
+// pm2 converts a pm2 ecosystem file in JSON form.
+func (im *importer) pm2(data []byte) error {
+	var file struct {
+		Apps []json.RawMessage `json:"apps"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("%v (ecosystem.config.js files can be converted to JSON with "+
+			`node -e 'console.log(JSON.stringify(require("./ecosystem.config.js")))')`, err)
+	}
+	var top map[string]json.RawMessage
+	json.Unmarshal(data, &top)
+	for key := range top {
+		if key != "apps" {
+			im.note("pm2 section %s isn't supported", key)
+		}
+	}
+	if len(file.Apps) == 0 {
+		return fmt.Errorf("no apps found")
+	}
+
+	for i, raw := range file.Apps {
+		var app pm2App
+		if err := json.Unmarshal(raw, &app); err != nil {
+			return fmt.Errorf("app %d: %v", i, err)
+		}
+		if app.Script == "" {
+			return fmt.Errorf("app %d: no script", i)
+		}
+		if app.Name == "" {
+			app.Name = strings.TrimSuffix(filepath.Base(app.Script), filepath.Ext(app.Script))
+		}
+		spec, err := im.pm2App(app)
+		if err != nil {
+			return fmt.Errorf("%s: %v", app.Name, err)
+		}
+
+		var options map[string]json.RawMessage
+		json.Unmarshal(raw, &options)
+		var unmapped []string
+		for key := range options {
+			if !pm2Mapped[key] {
+				unmapped = append(unmapped, key)
+			}
+		}
+		sort.Strings(unmapped)
+		for _, key := range unmapped {
+			im.note("%s: pm2 option %s isn't supported", app.Name, key)
+		}
+		im.config.Processes = append(im.config.Processes, spec)
+	}
+	return nil
+}
+
+func (im *importer) pm2App(app pm2App) (*pb.ProcessSpec, error) {
+	spec := &pb.ProcessSpec{Name: app.Name, Cwd: im.abs(app.Cwd), AutoRestart: "always"}
+	if spec.Cwd == "" {
+		spec.Cwd = im.abs(".")
+	}
+
+	args, err := stringOrList(app.Args)
+	if err != nil {
+		return nil, fmt.Errorf("args: %v", err)
+	}
+	interpreter := app.Interpreter
+	if interpreter == "" {
+		interpreter = pm2Interpreters[filepath.Ext(app.Script)]
+	}
+	if interpreter == "" || interpreter == "none" {
+		spec.Command = app.Script
+	} else {
+		interpreterArgs, err := stringOrList(app.InterpreterArgs)
+		if err != nil {
+			return nil, fmt.Errorf("interpreter_args: %v", err)
+		}
+		if interpreter == "node" {
+			nodeArgs, err := stringOrList(app.NodeArgs)
+			if err != nil {
+				return nil, fmt.Errorf("node_args: %v", err)
+			}
+			interpreterArgs = append(interpreterArgs, nodeArgs...)
+		}
+		spec.Command = interpreter
+		args = append(append(interpreterArgs, app.Script), args...)
+	}
+	spec.Args = args
+
+	if len(app.Env) > 0 {
+		spec.Env = make(map[string]string)
+		for k, v := range app.Env {
+			spec.Env[k] = fmt.Sprint(v)
+		}
+	}
+
+	if len(app.Instances) > 0 {
+		var instances any
+		json.Unmarshal(app.Instances, &instances)
+		switch v := instances.(type) {
+		case float64:
+			if v > 0 {
+				spec.Instances = int32(v)
+			} else {
+				// 0 and -1 mean as many as CPUs, or one fewer
+				spec.Instances = int32(max(runtime.NumCPU()+int(v), 1))
+				im.note("%s: instances %v became %d for the CPUs of this machine", app.Name, v, spec.Instances)
+			}
+		case string:
+			if v == "max" {
+				spec.Instances = int32(runtime.NumCPU())
+				im.note("%s: instances max became %d, the CPUs of this machine", app.Name, spec.Instances)
+			} else if n, err := strconv.Atoi(v); err == nil {
+				spec.Instances = int32(n)
+			} else {
+				return nil, fmt.Errorf("invalid instances %q", v)
+			}
+		}
+	}
+	if app.ExecMode == "cluster" || app.ExecMode == "cluster_mode" {
+		im.note("%s: cluster mode isn't supported; the replicas are separate processes, which need distinct ports (basePort)", app.Name)
+	}
+	if app.Autorestart != nil && !*app.Autorestart {
+		spec.AutoRestart = "never"
+	}
+
+	// watch is true, for the app's directory, or a list of paths
+	var watchAll bool
+	if json.Unmarshal(app.Watch, &watchAll) == nil {
+		if watchAll {
+			spec.Watch = &pb.WatchSpec{Paths: []string{spec.Cwd}}
+		}
+	} else if paths, err := stringOrList(app.Watch); err == nil && len(paths) > 0 {
+		spec.Watch = &pb.WatchSpec{}
+		for _, path := range paths {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(spec.Cwd, path)
+			}
+			spec.Watch.Paths = append(spec.Watch.Paths, path)
+		}
+	}
+	if spec.Watch != nil {
+		spec.Watch.Ignore, _ = stringOrList(app.IgnoreWatch)
+		if app.WatchDelay != nil {
+			spec.Watch.Debounce = (time.Duration(*app.WatchDelay) * time.Millisecond).String()
+		}
+	}
+
+	if app.MaxMemory != "" {
+		spec.MaxMemory, err = parseSize(app.MaxMemory)
+		if err != nil {
+			return nil, fmt.Errorf("max_memory_restart: %v", err)
+		}
+	}
+	return spec, nil
+}
+
+// stringOrList decodes an option that is a list of strings or a single
+// string of space separated words.
+func stringOrList(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var list []string
+	if json.Unmarshal(raw, &list) == nil {
+		return list, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, fmt.Errorf("expected a string or a list of strings")
+	}
+	return splitWords(s)
+}
+
+// splitWords splits a command line into words the way a shell would,
+// honoring quotes and backslashes but not expanding anything.
+func splitWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", s)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+var supervisordMapped = map[string]bool{
+	"command": true, "directory": true, "environment": true, "autorestart": true,
+	"numprocs": true, "process_name": true, "autostart": true, "exitcodes": true,
+}
+
+// supervisordExpansion matches the %(name)s expressions of supervisord.
+var supervisordExpansion = regexp.MustCompile(`%\(([A-Za-z_]+)\)[-#0 +]*[0-9]*[sd]`)
+
+// supervisord converts the [program:x] sections of a supervisord config.
+// The programs of a [group:x] section get a group=x label.
+func (im *importer) supervisord(data []byte) error {
+	sections, err := parseINI(data)
+	if err != nil {
+		return err
+	}
+	groups := make(map[string]string)
+	for _, section := range sections {
+		if name, ok := strings.CutPrefix(section.name, "group:"); ok {
+			for _, program := range strings.Split(section.values["programs"], ",") {
+				groups[strings.TrimSpace(program)] = name
+			}
+		}
+	}
+
+	for _, section := range sections {
+		kind, name, _ := strings.Cut(section.name, ":")
+		switch kind {
+		case "program":
+		case "group", "supervisord", "supervisorctl", "unix_http_server", "inet_http_server", "rpcinterface":
+			// the daemon's own settings
+			continue
+		default:
+			im.note("section [%s] isn't supported", section.name)
+			continue
+		}
+		spec, err := im.supervisordProgram(name, section.values)
+		if err != nil {
+			return fmt.Errorf("[%s]: %v", section.name, err)
+		}
+		if group, ok := groups[name]; ok {
+			spec.Labels = map[string]string{"group": group}
+		}
+		im.config.Processes = append(im.config.Processes, spec)
+	}
+	if len(im.config.Processes) == 0 {
+		return fmt.Errorf("no [program:x] sections found")
+	}
+	return nil
+}
+
+func (im *importer) supervisordProgram(name string, values map[string]string) (*pb.ProcessSpec, error) {
+	spec := &pb.ProcessSpec{Name: name, AutoRestart: "on-failure"}
+	if numprocs := values["numprocs"]; numprocs != "" {
+		n, err := strconv.Atoi(numprocs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid numprocs %q", numprocs)
+		}
+		if n > 1 {
+			spec.Instances = int32(n)
+		}
+	}
+	expand := func(key, s string) string {
+		return supervisordExpansion.ReplaceAllStringFunc(s, func(m string) string {
+			variable := supervisordExpansion.FindStringSubmatch(m)[1]
+			switch {
+			case variable == "program_name":
+				return name
+			case variable == "here":
+				return im.abs(".")
+			case variable == "process_num" && spec.Instances == 0:
+				return "0"
+			case strings.HasPrefix(variable, "ENV_"):
+				im.note("%s: %s was expanded with %s of this environment", name, key, strings.TrimPrefix(variable, "ENV_"))
+				return os.Getenv(strings.TrimPrefix(variable, "ENV_"))
+			}
+			im.note("%s: %%(%s) in %s can't be expanded; replicas get GOPM_INSTANCE_ID instead", name, variable, key)
+			return m
+		})
+	}
+
+	command, err := splitWords(expand("command", values["command"]))
+	if err != nil {
+		return nil, err
+	}
+	if len(command) == 0 {
+		return nil, fmt.Errorf("no command")
+	}
+	spec.Command, spec.Args = command[0], command[1:]
+	spec.Cwd = im.abs(expand("directory", values["directory"]))
+
+	if environment := values["environment"]; environment != "" {
+		env, err := parseSupervisordEnv(expand("environment", environment))
+		if err != nil {
+			return nil, err
+		}
+		spec.Env = env
+	}
+	switch values["autorestart"] {
+	case "", "unexpected":
+		if codes := values["exitcodes"]; codes != "" && codes != "0" {
+			im.note("%s: exitcodes=%s isn't supported; every exit code other than 0 counts as a failure", name, codes)
+		}
+	case "true":
+		spec.AutoRestart = "always"
+	case "false":
+		spec.AutoRestart = "never"
+	default:
+		return nil, fmt.Errorf("invalid autorestart %q", values["autorestart"])
+	}
+
+	if values["autostart"] == "false" {
+		im.note("%s: autostart=false isn't supported; apply starts every process", name)
+	}
+
+	var unmapped []string
+	for key := range values {
+		if !supervisordMapped[key] {
+			unmapped = append(unmapped, key)
+		}
+	}
+	sort.Strings(unmapped)
+	for _, key := range unmapped {
+		im.note("%s: supervisord option %s isn't supported", name, key)
+	}
+	return spec, nil
+}
+
+// parseSupervisordEnv parses KEY="value",KEY2=value2.
+func parseSupervisordEnv(s string) (map[string]string, error) {
+	env := make(map[string]string)
+	for s = strings.TrimSpace(s); s != ""; {
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid environment %q", s)
+		}
+		var value string
+		if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
+			end := strings.IndexByte(rest[1:], rest[0])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote in environment")
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+			rest = "," + rest
+		}
+		env[strings.TrimSpace(key)] = value
+		s = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), ","))
+	}
+	return env, nil
+}
+
+type iniSection struct {
+	name   string
+	values map[string]string
+}
+
+// parseINI parses the INI dialect of supervisord: ; and # comments,
+// key=value or key: value, and indented continuation lines.
+func parseINI(data []byte) ([]iniSection, error) {
+	var sections []iniSection
+	var current *iniSection
+	var lastKey string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			sections = append(sections, iniSection{name: strings.TrimSpace(line[1 : len(line)-1]), values: make(map[string]string)})
+			current = &sections[len(sections)-1]
+			lastKey = ""
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("line %d: outside of a section", n)
+		}
+		if (raw[0] == ' ' || raw[0] == '\t') && lastKey != "" {
+			current.values[lastKey] += "\n" + line
+			continue
+		}
+		i := strings.IndexAny(line, "=:")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected key=value", n)
+		}
+		lastKey = strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+		// inline comments need a space before the ;
+		if j := strings.Index(value, " ;"); j >= 0 {
+			value = strings.TrimSpace(value[:j])
+		}
+		current.values[lastKey] = value
+	}
+	return sections, scanner.Err()
+}
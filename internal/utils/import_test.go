@@ -0,0 +1,416 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	pb "github.com/brianykl/gopm/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "  ", want: nil},
+		{in: "node server.js", want: []string{"node", "server.js"}},
+		{in: " a\tb\nc  ", want: []string{"a", "b", "c"}},
+		{in: `echo "hello world"`, want: []string{"echo", "hello world"}},
+		{in: `echo 'a "b" c'`, want: []string{"echo", `a "b" c`}},
+		{in: `echo "a 'b' c"`, want: []string{"echo", "a 'b' c"}},
+		{in: `echo a\ b`, want: []string{"echo", "a b"}},
+		{in: `echo "a\"b"`, want: []string{"echo", `a"b`}},
+		{in: `echo 'a\b'`, want: []string{"echo", `a\b`}},
+		{in: `echo "" ''`, want: []string{"echo", "", ""}},
+		{in: `--opt="x y"z`, want: []string{"--opt=x yz"}},
+		{in: `echo $HOME`, want: []string{"echo", "$HOME"}},
+		{in: `echo "open`, wantErr: true},
+		{in: `echo 'open`, wantErr: true},
+		{in: `echo \`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := splitWords(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("splitWords(%q) = %q, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("splitWords(%q) error = %v", tt.in, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("splitWords(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseINI(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    []iniSection
+		wantErr bool
+	}{
+		{
+			name: "sections and values",
+			in: "; comment\n[supervisord]\nlogfile=/tmp/x.log\n\n# comment\n" +
+				"[program:web]\nCommand = node server.js\ndirectory: /srv/web\n",
+			want: []iniSection{
+				{name: "supervisord", values: map[string]string{"logfile": "/tmp/x.log"}},
+				{name: "program:web", values: map[string]string{"command": "node server.js", "directory": "/srv/web"}},
+			},
+		},
+		{
+			name: "inline comments",
+			in:   "[program:web]\ncommand=echo a;b ; the comment\n",
+			want: []iniSection{
+				{name: "program:web", values: map[string]string{"command": "echo a;b"}},
+			},
+		},
+		{
+			name: "continuation lines",
+			in:   "[program:web]\nenvironment=A=1,\n  B=2\ncommand=run\n",
+			want: []iniSection{
+				{name: "program:web", values: map[string]string{"environment": "A=1,\nB=2", "command": "run"}},
+			},
+		},
+		{
+			name: "empty section",
+			in:   "[ group:all ]\n",
+			want: []iniSection{{name: "group:all", values: map[string]string{}}},
+		},
+		{
+			name:    "value outside of a section",
+			in:      "command=run\n",
+			wantErr: true,
+		},
+		{
+			name:    "line without a value",
+			in:      "[program:web]\ncommand\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseINI([]byte(tt.in))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseINI() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseINI() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseINI() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSupervisordEnv(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[string]string
+		wantErr bool
+	}{
+		{in: "A=1", want: map[string]string{"A": "1"}},
+		{in: `A="x, y",B=2`, want: map[string]string{"A": "x, y", "B": "2"}},
+		{in: `A='1', B=""`, want: map[string]string{"A": "1", "B": ""}},
+		{in: "A=1,\nB=2", want: map[string]string{"A": "1", "B": "2"}},
+		{in: "A", wantErr: true},
+		{in: `A="open`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseSupervisordEnv(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseSupervisordEnv(%q) = %v, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSupervisordEnv(%q) error = %v", tt.in, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSupervisordEnv(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuessFormat(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"Procfile", FormatProcfile},
+		{"/app/Procfile.dev", FormatProcfile},
+		{"ecosystem.json", FormatPM2},
+		{"supervisord.conf", FormatSupervisord},
+		{"conf.d/web.ini", FormatSupervisord},
+		{"ecosystem.config.js", ""},
+	}
+	for _, tt := range tests {
+		if got := guessFormat(tt.file); got != tt.want {
+			t.Errorf("guessFormat(%q) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+}
+
+// importTest is a file to convert and the processes and notes expected
+// from it. The file is converted in a temporary directory holding files.
+type importTest struct {
+	name  string
+	in    string
+	files map[string]string
+	// want gets the directory of the file for the paths in it, which
+	// notes show as $DIR
+	want    func(dir string) []*pb.ProcessSpec
+	notes   []string
+	wantErr string
+}
+
+func runImportTests(t *testing.T, convert func(*importer, []byte) error, tests []importTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			im := &importer{dir: dir}
+			err := convert(im, []byte(tt.in))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("import error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("import error = %v", err)
+			}
+			want := tt.want(dir)
+			if len(im.config.Processes) != len(want) {
+				t.Fatalf("imported %d processes, want %d: %v", len(im.config.Processes), len(want), im.config.Processes)
+			}
+			for i, spec := range im.config.Processes {
+				if !proto.Equal(spec, want[i]) {
+					t.Errorf("process %d = %v, want %v", i, spec, want[i])
+				}
+			}
+			for i, note := range im.notes {
+				im.notes[i] = strings.ReplaceAll(note, dir, "$DIR")
+			}
+			if !slices.Equal(im.notes, tt.notes) {
+				t.Errorf("notes = %q, want %q", im.notes, tt.notes)
+			}
+		})
+	}
+}
+
+func TestImportProcfile(t *testing.T) {
+	runImportTests(t, (*importer).procfile, []importTest{
+		{
+			name: "processes get ports",
+			in:   "# the app\nweb: bundle exec rails s -p $PORT\n\nworker:sidekiq\n",
+			want: func(dir string) []*pb.ProcessSpec {
+				return []*pb.ProcessSpec{
+					{Name: "web", Command: "sh", Args: []string{"-c", "bundle exec rails s -p $PORT"}, AutoRestart: "always", Cwd: dir, BasePort: 5000},
+					{Name: "worker", Command: "sh", Args: []string{"-c", "sidekiq"}, AutoRestart: "always", Cwd: dir, BasePort: 5100},
+				}
+			},
+		},
+		{
+			name:  "env file",
+			in:    "web: ./server\n",
+			files: map[string]string{".env": "# settings\nexport A=\"1 2\"\nB = 'x'\nC=3\nnot a variable\n"},
+			want: func(dir string) []*pb.ProcessSpec {
+				return []*pb.ProcessSpec{{
+					Name: "web", Command: "sh", Args: []string{"-c", "./server"}, AutoRestart: "always", Cwd: dir, BasePort: 5000,
+					Env: map[string]string{"A": "1 2", "B": "x", "C": "3"},
+				}}
+			},
+			notes: []string{"the variables of $DIR/.env were added to every process"},
+		},
+		{
+			name:    "invalid line",
+			in:      "web: ./server\nnot a process\n",
+			wantErr: "line 2: expected <name>: <command>",
+		},
+		{
+			name:    "no processes",
+			in:      "# nothing\n",
+			wantErr: "no processes found",
+		},
+	})
+}
+
+func TestImportPM2(t *testing.T) {
+	runImportTests(t, (*importer).pm2, []importTest{
+		{
+			name: "interpreters and options",
+			in: `{"apps": [
+				{"name": "api", "script": "server.js", "args": "--port 3000", "node_args": ["--inspect"],
+				 "cwd": "api", "env": {"NODE_ENV": "production", "WORKERS": 4}, "instances": 2,
+				 "max_memory_restart": "200M", "log_date_format": "YYYY", "merge_logs": true},
+				{"script": "/usr/bin/worker", "args": ["a b", "c"], "autorestart": false,
+				 "watch": ["src", "/etc/worker"], "ignore_watch": "node_modules", "watch_delay": 500},
+				{"name": "py", "script": "job.py", "interpreter_args": "-u", "watch": true, "instances": "3"}
+			], "deploy": {}}`,
+			want: func(dir string) []*pb.ProcessSpec {
+				return []*pb.ProcessSpec{
+					{
+						Name: "api", Command: "node", Args: []string{"--inspect", "server.js", "--port", "3000"},
+						Cwd: filepath.Join(dir, "api"), AutoRestart: "always", Instances: 2, MaxMemory: 200 << 20,
+						Env: map[string]string{"NODE_ENV": "production", "WORKERS": "4"},
+					},
+					{
+						Name: "worker", Command: "/usr/bin/worker", Args: []string{"a b", "c"}, Cwd: dir, AutoRestart: "never",
+						Watch: &pb.WatchSpec{Paths: []string{filepath.Join(dir, "src"), "/etc/worker"}, Ignore: []string{"node_modules"}, Debounce: "500ms"},
+					},
+					{
+						Name: "py", Command: "python3", Args: []string{"-u", "job.py"}, Cwd: dir, AutoRestart: "always", Instances: 3,
+						Watch: &pb.WatchSpec{Paths: []string{dir}},
+					},
+				}
+			},
+			notes: []string{
+				"pm2 section deploy isn't supported",
+				"api: pm2 option log_date_format isn't supported",
+				"api: pm2 option merge_logs isn't supported",
+			},
+		},
+		{
+			name: "no interpreter",
+			in:   `{"apps": [{"name": "bin", "script": "./run.sh", "interpreter": "none", "exec_mode": "cluster"}]}`,
+			want: func(dir string) []*pb.ProcessSpec {
+				return []*pb.ProcessSpec{{Name: "bin", Command: "./run.sh", Cwd: dir, AutoRestart: "always"}}
+			},
+			notes: []string{"bin: cluster mode isn't supported; the replicas are separate processes, which need distinct ports (basePort)"},
+		},
+		{
+			name:    "no apps",
+			in:      `{"apps": []}`,
+			wantErr: "no apps found",
+		},
+		{
+			name:    "no script",
+			in:      `{"apps": [{"name": "api"}]}`,
+			wantErr: "app 0: no script",
+		},
+		{
+			name:    "invalid args",
+			in:      `{"apps": [{"name": "api", "script": "a.js", "args": 3}]}`,
+			wantErr: "api: args: expected a string or a list of strings",
+		},
+		{
+			name:    "invalid memory",
+			in:      `{"apps": [{"name": "api", "script": "a.js", "max_memory_restart": "lots"}]}`,
+			wantErr: "api: max_memory_restart: invalid size",
+		},
+		{
+			name:    "javascript",
+			in:      `module.exports = {apps: []}`,
+			wantErr: "can be converted to JSON",
+		},
+	})
+}
+
+func TestImportSupervisord(t *testing.T) {
+	runImportTests(t, (*importer).supervisord, []importTest{
+		{
+			name: "programs and groups",
+			in: `[supervisord]
+logfile=/var/log/supervisord.log
+
+[group:app]
+programs=web,worker
+
+[program:web]
+command=/usr/bin/python3 -m http.server --directory "%(here)s/public"
+directory=%(here)s
+environment=PORT="8000",NAME=%(program_name)s
+autorestart=true
+stdout_logfile=/var/log/web.log
+
+[program:worker]
+command=./worker --id %(process_num)d
+numprocs=1
+autorestart=false
+autostart=false
+
+[program:cron]
+command=cron -f
+exitcodes=0,2
+
+[eventlistener:memmon]
+command=memmon
+`,
+			want: func(dir string) []*pb.ProcessSpec {
+				return []*pb.ProcessSpec{
+					{
+						Name: "web", Command: "/usr/bin/python3", Args: []string{"-m", "http.server", "--directory", dir + "/public"},
+						Cwd: dir, AutoRestart: "always", Env: map[string]string{"PORT": "8000", "NAME": "web"},
+						Labels: map[string]string{"group": "app"},
+					},
+					{
+						Name: "worker", Command: "./worker", Args: []string{"--id", "0"}, AutoRestart: "never",
+						Labels: map[string]string{"group": "app"},
+					},
+					{Name: "cron", Command: "cron", Args: []string{"-f"}, AutoRestart: "on-failure"},
+				}
+			},
+			notes: []string{
+				"web: supervisord option stdout_logfile isn't supported",
+				"worker: autostart=false isn't supported; apply starts every process",
+				"cron: exitcodes=0,2 isn't supported; every exit code other than 0 counts as a failure",
+				"section [eventlistener:memmon] isn't supported",
+			},
+		},
+		{
+			name: "replicas",
+			in:   "[program:web]\ncommand=./web --port 80%(process_num)02d\nnumprocs=3\n",
+			want: func(dir string) []*pb.ProcessSpec {
+				return []*pb.ProcessSpec{
+					{Name: "web", Command: "./web", Args: []string{"--port", "80%(process_num)02d"}, AutoRestart: "on-failure", Instances: 3},
+				}
+			},
+			notes: []string{"web: %(process_num) in command can't be expanded; replicas get GOPM_INSTANCE_ID instead"},
+		},
+		{
+			name:    "no command",
+			in:      "[program:web]\ndirectory=/srv\n",
+			wantErr: "[program:web]: no command",
+		},
+		{
+			name:    "invalid autorestart",
+			in:      "[program:web]\ncommand=./web\nautorestart=sometimes\n",
+			wantErr: `invalid autorestart "sometimes"`,
+		},
+		{
+			name:    "invalid numprocs",
+			in:      "[program:web]\ncommand=./web\nnumprocs=many\n",
+			wantErr: `invalid numprocs "many"`,
+		},
+		{
+			name:    "no programs",
+			in:      "[supervisord]\nnodaemon=true\n",
+			wantErr: "no [program:x] sections found",
+		},
+	})
+}
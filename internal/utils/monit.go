@@ -0,0 +1,510 @@
+package utils
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	pb "github.com/brianykl/gopm/proto"
+	"golang.org/x/term"
+)
+
+const (
+	monitLogLines = 500
+	monitHelp     = "↑/↓ select  r restart  s stop  x signal  c scale  q quit"
+)
+
+// monit is the state of the dashboard. It is owned by the loop in RunMonit;
+// RPCs run in goroutines and report back over channels.
+type monit struct {
+	client   pb.ProcessManagerClient
+	ctx      context.Context
+	selector string
+	// namespace is the current one, left out of names
+	namespace string
+
+	processes []*pb.ProcessInfo
+	selected  string
+	offset    int
+
+	logs       []string
+	logsOf     string
+	logsCancel context.CancelFunc
+	logsGen    int
+
+	// prompt is the question being asked in the status line, if any, and
+	// answer what has been typed so far
+	prompt string
+	answer string
+	status string
+}
+
+type monitLog struct {
+	gen  int
+	text string
+}
+
+// monitOptions are the flags of monit.
+type monitOptions struct {
+	interval time.Duration
+	selector string
+}
+
+func monitFlags(o *monitOptions) *flag.FlagSet {
+	fs := newFlagSet("monit")
+	fs.DurationVar(&o.interval, "interval", time.Second, "how often the table is refreshed")
+	fs.StringVar(&o.selector, "l", "", selectorUsage)
+	return fs
+}
+
+// RunMonit shows a live, full-screen table of the processes with the logs
+// of the selected one and keys to act on it.
+func RunMonit(client pb.ProcessManagerClient, ctx context.Context, args []string) error {
+	var o monitOptions
+	fs := monitFlags(&o)
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return UsageError{"gopm monit <flag>"}
+	}
+	stdin, stdout := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(stdin) || !term.IsTerminal(stdout) {
+		return fmt.Errorf("monit needs a terminal")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	m := &monit{client: client, ctx: ctx, selector: o.selector, namespace: CurrentNamespace(), status: monitHelp}
+	// fail before touching the terminal if the daemon can't be reached
+	if err := m.refresh(); err != nil {
+		return err
+	}
+
+	state, err := term.MakeRaw(stdin)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(stdin, state)
+	// alternate screen, hidden cursor
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan []byte)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if n > 0 {
+				keys <- append([]byte(nil), buf[:n]...)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+
+	logs := make(chan monitLog, 100)
+	results := make(chan string, 10)
+	changed := m.watchEvents()
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+
+	for {
+		m.followLogs(logs)
+		m.draw()
+
+		select {
+		case data := <-keys:
+			if quit := m.handleKeys(data, results); quit {
+				return nil
+			}
+		case l := <-logs:
+			if l.gen == m.logsGen {
+				m.logs = append(m.logs, l.text)
+				if len(m.logs) > monitLogLines {
+					m.logs = m.logs[len(m.logs)-monitLogLines:]
+				}
+			}
+		case msg := <-results:
+			m.status = msg
+			m.refresh()
+		case <-changed:
+			m.refresh()
+		case <-ticker.C:
+			if err := m.refresh(); err != nil {
+				m.status = "error: " + err.Error()
+			}
+		case <-winch:
+		}
+	}
+}
+
+// refresh fetches the process table.
+func (m *monit) refresh() error {
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+	defer cancel()
+	res, err := m.client.ListProcess(ctx, &pb.ListRequest{Verbose: true, Selector: m.selector})
+	if err != nil {
+		return err
+	}
+	m.processes = res.Processes
+	sort.Slice(m.processes, func(i, j int) bool { return m.processes[i].Name < m.processes[j].Name })
+	if m.index() < 0 && len(m.processes) > 0 {
+		m.selected = m.processes[0].Name
+	}
+	return nil
+}
+
+// watchEvents signals whenever a process changes state, so the table
+// doesn't wait for the next refresh.
+func (m *monit) watchEvents() <-chan struct{} {
+	changed := make(chan struct{}, 1)
+	stream, err := m.client.StreamEvents(m.ctx, &pb.EventRequest{Follow: true})
+	if err != nil {
+		return changed
+	}
+	since := time.Now()
+	go func() {
+		for {
+			e, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			if e.Time.AsTime().Before(since) {
+				continue
+			}
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return changed
+}
+
+// followLogs streams the logs of the selected process once it changes.
+func (m *monit) followLogs(logs chan<- monitLog) {
+	if m.selected == m.logsOf {
+		return
+	}
+	if m.logsCancel != nil {
+		m.logsCancel()
+	}
+	m.logsGen++
+	m.logs, m.logsOf = nil, m.selected
+	if m.selected == "" {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.logsCancel = cancel
+	gen := m.logsGen
+	stream, err := m.client.StreamLogs(ctx, &pb.LogRequest{Name: m.selected, Follow: true})
+	if err != nil {
+		m.logs = []string{"error: " + err.Error()}
+		return
+	}
+	go func() {
+		for {
+			line, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			select {
+			case logs <- monitLog{gen: gen, text: line.Text}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (m *monit) index() int {
+	for i, p := range m.processes {
+		if p.Name == m.selected {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *monit) move(delta int) {
+	if len(m.processes) == 0 {
+		return
+	}
+	i := m.index() + delta
+	i = max(0, min(i, len(m.processes)-1))
+	m.selected = m.processes[i].Name
+}
+
+// handleKeys applies a chunk of input and tells whether to quit.
+func (m *monit) handleKeys(data []byte, results chan<- string) bool {
+	for len(data) > 0 {
+		key := string(data[:1])
+		switch {
+		case strings.HasPrefix(string(data), "\x1b[A"), strings.HasPrefix(string(data), "\x1bOA"):
+			key, data = "up", data[3:]
+		case strings.HasPrefix(string(data), "\x1b[B"), strings.HasPrefix(string(data), "\x1bOB"):
+			key, data = "down", data[3:]
+		case data[0] == 0x1b && len(data) > 1:
+			// another escape sequence, which has no binding
+			return false
+		default:
+			data = data[1:]
+		}
+		if m.prompt != "" {
+			m.promptKey(key, results)
+			continue
+		}
+
+		switch key {
+		case "q", "\x03":
+			return true
+		case "up", "k":
+			m.move(-1)
+		case "down", "j":
+			m.move(1)
+		case "r":
+			m.act(results, "restart", func(ctx context.Context, name string) (string, error) {
+				stream, err := m.client.RestartProcess(ctx, &pb.RestartRequest{Name: name})
+				if err != nil {
+					return "", err
+				}
+				res, err := stream.Recv()
+				if err != nil {
+					return "", err
+				}
+				return res.Message, nil
+			})
+		case "s":
+			m.act(results, "stop", func(ctx context.Context, name string) (string, error) {
+				res, err := m.client.StopProcess(ctx, &pb.StopRequest{Name: name})
+				if err != nil {
+					return "", err
+				}
+				return res.Message, nil
+			})
+		case "x":
+			if m.selected != "" {
+				m.prompt, m.answer = "signal to send to "+shortName(m.selected, m.namespace)+": ", ""
+			}
+		case "c":
+			if m.selected != "" {
+				m.prompt, m.answer = "instances of "+shortName(groupName(m.selected), m.namespace)+": ", ""
+			}
+		}
+	}
+	return false
+}
+
+// promptKey edits the answer to the current prompt and runs the action
+// once it is confirmed with enter. Escape cancels.
+func (m *monit) promptKey(key string, results chan<- string) {
+	switch key {
+	case "\x1b", "\x03":
+		m.prompt, m.status = "", monitHelp
+	case "\x7f", "\b":
+		if len(m.answer) > 0 {
+			m.answer = m.answer[:len(m.answer)-1]
+		}
+	case "\r", "\n":
+		prompt, answer := m.prompt, strings.TrimSpace(m.answer)
+		m.prompt = ""
+		if answer == "" {
+			m.status = monitHelp
+			return
+		}
+		if strings.HasPrefix(prompt, "signal") {
+			m.act(results, "signal", func(ctx context.Context, name string) (string, error) {
+				res, err := m.client.SignalProcess(ctx, &pb.SignalRequest{Name: name, Signal: answer})
+				if err != nil {
+					return "", err
+				}
+				return res.Message, nil
+			})
+			return
+		}
+		instances, err := strconv.Atoi(answer)
+		if err != nil || instances < 0 {
+			m.status = fmt.Sprintf("invalid instance count %q", answer)
+			return
+		}
+		m.act(results, "scale", func(ctx context.Context, name string) (string, error) {
+			res, err := m.client.ScaleProcess(ctx, &pb.ScaleRequest{Name: groupName(name), Instances: int32(instances)})
+			if err != nil {
+				return "", err
+			}
+			return res.Message, nil
+		})
+	default:
+		if len(key) == 1 && key[0] >= ' ' && key[0] < 0x7f {
+			m.answer += key
+		}
+	}
+}
+
+// act runs op on the selected process in the background and reports the
+// outcome in the status line.
+func (m *monit) act(results chan<- string, verb string, op func(ctx context.Context, name string) (string, error)) {
+	name := m.selected
+	if name == "" {
+		return
+	}
+	m.status = fmt.Sprintf("%s %s...", verb, shortName(name, m.namespace))
+	go func() {
+		ctx, cancel := context.WithTimeout(m.ctx, 30*time.Second)
+		defer cancel()
+		msg, err := op(ctx, name)
+		if err != nil {
+			msg = fmt.Sprintf("%s %s failed: %v", verb, shortName(name, m.namespace), err)
+		}
+		results <- msg
+	}()
+}
+
+// groupName strips the replica index from an instance name.
+func groupName(name string) string {
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		return name[:i]
+	}
+	return name
+}
+
+func (m *monit) draw() {
+	cols, rows, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || rows < 6 {
+		cols, rows = 80, 24
+	}
+	var screen []string
+	line := func(format string, args ...any) {
+		screen = append(screen, truncate(fmt.Sprintf(format, args...), cols))
+	}
+
+	line("\x1b[1mgopm monit\x1b[0m  namespace %s  %s", m.namespace, time.Now().Format(time.TimeOnly))
+	const row = "%-24s %-10s %8s %7s %10s %8s %10s"
+	line("\x1b[7m"+row+"\x1b[0m", "NAME", "STATUS", "PID", "CPU", "MEMORY", "RESTARTS", "UPTIME")
+
+	// the table takes up to half the screen and scrolls with the selection
+	height := max(1, min(len(m.processes), (rows-4)/2))
+	if i := m.index(); i >= 0 {
+		if i < m.offset {
+			m.offset = i
+		} else if i >= m.offset+height {
+			m.offset = i - height + 1
+		}
+	}
+	m.offset = max(0, min(m.offset, len(m.processes)-height))
+	if len(m.processes) == 0 {
+		line("no processes")
+	}
+	for _, p := range m.processes[m.offset:min(len(m.processes), m.offset+height)] {
+		pid, uptime := "-", "-"
+		if p.Status == "running" {
+			pid = strconv.Itoa(int(p.Pid))
+			if p.StartedAt != nil {
+				uptime = formatUptime(time.Since(p.StartedAt.AsTime()))
+			}
+		}
+		text := fmt.Sprintf(row, shortName(p.Name, m.namespace), p.Status, pid, fmt.Sprintf("%.1f%%", p.CpuPercent),
+			formatBytes(p.MemoryBytes), strconv.Itoa(int(p.Restarts)), uptime)
+		if p.Name == m.selected {
+			text = "\x1b[1;36m" + text + "\x1b[0m"
+		}
+		line("%s", text)
+	}
+
+	title := "logs"
+	if m.logsOf != "" {
+		title = "logs of " + shortName(m.logsOf, m.namespace)
+	}
+	line("\x1b[7m %-*s\x1b[0m", cols-1, title)
+	logRows := rows - len(screen) - 1
+	logs := m.logs
+	if len(logs) > logRows {
+		logs = logs[len(logs)-logRows:]
+	}
+	for _, l := range logs {
+		line("%s", sanitize(l))
+	}
+	for len(screen) < rows-1 {
+		screen = append(screen, "")
+	}
+
+	status := m.status
+	if m.prompt != "" {
+		status = m.prompt + m.answer + "_"
+	}
+	screen = append(screen, truncate(status, cols))
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, l := range screen {
+		b.WriteString(l)
+		b.WriteString("\x1b[K")
+		if i < len(screen)-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	os.Stdout.WriteString(b.String())
+}
+
+// truncate cuts s to width visible characters, skipping over escape
+// sequences.
+func truncate(s string, width int) string {
+	var b strings.Builder
+	visible, escape := 0, false
+	for _, r := range s {
+		switch {
+		case r == 0x1b:
+			escape = true
+		case escape:
+			if r >= '@' && r <= '~' && r != '[' {
+				escape = false
+			}
+		default:
+			if visible >= width {
+				continue
+			}
+			visible++
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// sanitize drops control characters from process output so they can't move
+// the cursor.
+func sanitize(s string) string {
+	s = strings.ReplaceAll(s, "\t", "    ")
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func formatUptime(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%ds", int(d.Minutes()), int(d.Seconds())%60)
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+}
@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	pb "github.com/brianykl/gopm/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats. The machine-readable ones print the response messages
+// as they are defined in proto/process.proto, with every field present.
+const (
+	OutputText     = ""
+	OutputJSON     = "json"
+	OutputYAML     = "yaml"
+	OutputTable    = "table"
+	OutputWide     = "wide"
+	OutputTemplate = "template"
+)
+
+type outputFormat struct {
+	kind string
+	tmpl *template.Template
+	// documents counts printed YAML documents, which need separators
+	documents int
+}
+
+// output is the format chosen with --output, before or after the command.
+var output outputFormat
+
+// SetOutput selects the output format: json, yaml, table, wide or
+// template=<text/template>.
+func SetOutput(s string) error {
+	kind, text, _ := strings.Cut(s, "=")
+	switch kind {
+	case OutputText, OutputJSON, OutputYAML, OutputTable, OutputWide:
+		output = outputFormat{kind: kind}
+		return nil
+	case OutputTemplate:
+		tmpl, err := template.New("output").Option("missingkey=error").Parse(text)
+		if err != nil {
+			return fmt.Errorf("output template: %v", err)
+		}
+		output = outputFormat{kind: kind, tmpl: tmpl}
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q (json|yaml|table|wide|template=...)", s)
+}
+
+const outputUsage = "output format: json, yaml, table, wide or template=<go template>"
+
+// newFlagSet returns a flag set for a command that also accepts --output.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := commandFlagSet(name)
+	fs.Func("output", outputUsage, SetOutput)
+	fs.Func("o", "shorthand for --output", SetOutput)
+	return fs
+}
+
+// machineOutput tells whether responses are printed as data rather than
+// as text.
+func machineOutput() bool {
+	switch output.kind {
+	case OutputJSON, OutputYAML, OutputTemplate:
+		return true
+	}
+	return false
+}
+
+var marshalOptions = protojson.MarshalOptions{EmitUnpopulated: true}
+
+// printMessage prints m in the machine-readable format. Streamed messages
+// are printed one per line for JSON and as separate documents for YAML.
+func printMessage(m proto.Message) error {
+	data, err := marshalOptions.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if output.kind == OutputJSON {
+		// protojson output isn't stable byte for byte, so compact it
+		var value any
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		data, err = json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Printf("%s\n", data)
+		return err
+	}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch output.kind {
+	case OutputYAML:
+		if output.documents > 0 {
+			fmt.Println("---")
+		}
+		output.documents++
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(value); err != nil {
+			return err
+		}
+		return enc.Close()
+	case OutputTemplate:
+		var b strings.Builder
+		if err := output.tmpl.Execute(&b, value); err != nil {
+			return err
+		}
+		text := b.String()
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		_, err := os.Stdout.WriteString(text)
+		return err
+	}
+	return fmt.Errorf("output format %q is not machine readable", output.kind)
+}
+
+// newTable returns a writer that aligns tab separated columns.
+func newTable(header ...string) *tabwriter.Writer {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	return w
+}
+
+func tableOutput() bool {
+	return output.kind == OutputTable || output.kind == OutputWide
+}
+
+// printTable prints a listing with a row per process, task and job. The
+// wide format adds usage, the last exit and labels.
+func printTable(res *pb.ListResponse, display func(string) string) {
+	wide := output.kind == OutputWide
+	header := []string{"NAME", "TYPE", "STATUS", "PID", "RESTARTS"}
+	if wide {
+		header = append(header, "CPU", "MEMORY", "LAST EXIT", "LABELS")
+	}
+	hosts := fanningOut()
+	if hosts {
+		header = append([]string{"HOST"}, header...)
+	}
+	w := newTable(header...)
+	row := func(host string, columns ...string) {
+		if hosts {
+			columns = append([]string{host}, columns...)
+		}
+		for i, c := range columns {
+			if c == "" {
+				columns[i] = "-"
+			}
+		}
+		if !wide {
+			columns = columns[:len(header)]
+		}
+		fmt.Fprintln(w, strings.Join(columns, "\t"))
+	}
+
+	for _, p := range res.Processes {
+		exit := ""
+		if p.ExitReason != "" {
+			exit = formatExit(p)
+		}
+		pid := ""
+		if p.Pid != 0 {
+			pid = fmt.Sprint(p.Pid)
+		}
+		row(p.Host, display(p.Name), "process", p.Status, pid, fmt.Sprint(p.Restarts),
+			fmt.Sprintf("%.1f%%", p.CpuPercent), formatBytes(p.MemoryBytes), exit, formatLabels(p.Labels))
+	}
+	for _, t := range res.Tasks {
+		exit := ""
+		if t.Result.FinishedAt != nil {
+			exit = fmt.Sprintf("exit code %d", t.Result.ExitCode)
+		}
+		row(t.Host, display(t.Name), "task", t.Result.Status, "", "", "", "", exit, "")
+	}
+	for _, j := range res.Jobs {
+		status, exit := "scheduled", ""
+		if len(j.Runs) > 0 {
+			run := j.Runs[len(j.Runs)-1]
+			status = run.Status
+			if run.FinishedAt != nil {
+				exit = fmt.Sprintf("exit code %d", run.ExitCode)
+			}
+		}
+		row(j.Host, display(j.Spec.Name), "job", status, "", "", "", "", exit, "")
+	}
+	w.Flush()
+}
@@ -0,0 +1,274 @@
+package utils
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/brianykl/gopm/internal/server"
+)
+
+// unitName is the systemd service of the daemon.
+const unitName = "gopm.service"
+
+// serverPathFlags are the flags of the daemon that name files, made
+// absolute when written to a unit.
+var serverPathFlags = map[string]bool{
+	"tls-cert": true, "tls-key": true, "tls-client-ca": true, "token-file": true, "state": true,
+}
+
+// startupOptions are the flags of startup and unstartup; server and check
+// are only set by startupFlags.
+type startupOptions struct {
+	userUnit, dryRun bool
+	server           *server.Options
+	check            func() error
+}
+
+func startupFlags(o *startupOptions) *flag.FlagSet {
+	fs := commandFlagSet("startup")
+	fs.BoolVar(&o.userUnit, "user", false, "install a user service, started when the user logs in (or at boot with lingering)")
+	fs.BoolVar(&o.dryRun, "dry-run", false, "print the unit instead of installing it")
+	o.server, o.check = serverFlags(fs)
+	fs.Lookup("state").Usage = "file to save processes and jobs to (default: ~/.local/state/gopm/state.json)"
+	fs.Lookup("resurrect").Usage = "ignored, the service always resurrects"
+	return fs
+}
+
+func unstartupFlags(o *startupOptions) *flag.FlagSet {
+	fs := commandFlagSet("unstartup")
+	fs.BoolVar(&o.userUnit, "user", false, "remove the user service")
+	fs.BoolVar(&o.dryRun, "dry-run", false, "print what would be removed")
+	return fs
+}
+
+// RunStartup installs a systemd service that runs the daemon at boot,
+// bringing back the processes and jobs it had when it went down.
+func RunStartup(args []string) error {
+	var o startupOptions
+	fs := startupFlags(&o)
+	if err := parseInitSystem(fs, args); err != nil {
+		return err
+	}
+	if err := o.check(); err != nil {
+		return err
+	}
+	if flagGiven(fs, "state") && o.server.StateFile == "" {
+		return fmt.Errorf("the service needs a --state file to resurrect processes from")
+	}
+
+	account, err := serviceUser(o.userUnit)
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+
+	execStart := []string{exe, "init", "--resurrect"}
+	var visitErr error
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "user" || f.Name == "dry-run" || f.Name == "resurrect" {
+			return
+		}
+		value := f.Value.String()
+		if serverPathFlags[f.Name] {
+			value, visitErr = filepath.Abs(value)
+		}
+		if f.Name == "listen" {
+			if path, ok := strings.CutPrefix(value, "unix:"); ok {
+				path, visitErr = filepath.Abs(strings.TrimPrefix(path, "//"))
+				value = "unix:" + path
+			}
+		}
+		execStart = append(execStart, "--"+f.Name+"="+value)
+	})
+	if visitErr != nil {
+		return visitErr
+	}
+	if o.server.StateFile == "" {
+		execStart = append(execStart, "--state="+server.StatePath(account.HomeDir))
+	}
+	if o.server.Token != "" && !flagGiven(fs, "token-file") {
+		fmt.Fprintln(os.Stderr, "warning: GOPM_TOKEN isn't written to the unit, use --token-file to require a token")
+	}
+
+	unit := systemdUnit(execStart, account, o.userUnit)
+	if o.dryRun {
+		fmt.Print(unit)
+		return nil
+	}
+
+	path, err := unitPath(o.userUnit)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return unitError(err, o.userUnit)
+	}
+	if err := os.WriteFile(path, []byte(unit), 0o644); err != nil {
+		return unitError(err, o.userUnit)
+	}
+	fmt.Printf("wrote %s\n", path)
+
+	systemctl(o.userUnit, "daemon-reload")
+	systemctl(o.userUnit, "enable", "--now", unitName)
+	if o.userUnit {
+		fmt.Printf("to start it at boot rather than at login, run: loginctl enable-linger %s\n", account.Username)
+	}
+	return nil
+}
+
+// RunUnstartup disables and removes the service installed by startup. The
+// daemon is stopped, but the state file is kept.
+func RunUnstartup(args []string) error {
+	var o startupOptions
+	fs := unstartupFlags(&o)
+	if err := parseInitSystem(fs, args); err != nil {
+		return err
+	}
+
+	path, err := unitPath(o.userUnit)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("no service installed at %s", path)
+	}
+	if o.dryRun {
+		fmt.Printf("would disable %s and remove %s\n", unitName, path)
+		return nil
+	}
+
+	systemctl(o.userUnit, "disable", "--now", unitName)
+	if err := os.Remove(path); err != nil {
+		return unitError(err, o.userUnit)
+	}
+	fmt.Printf("removed %s\n", path)
+	systemctl(o.userUnit, "daemon-reload")
+	return nil
+}
+
+// parseInitSystem parses the flags of startup and unstartup, which may
+// come before or after the init system. Only systemd is supported.
+func parseInitSystem(fs *flag.FlagSet, args []string) error {
+	usage := UsageError{"gopm " + fs.Name() + " [systemd] [flags]"}
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if args[0] != "systemd" {
+			return fmt.Errorf("unsupported init system %q, only systemd is", args[0])
+		}
+		args = args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch {
+	case fs.NArg() == 0:
+		return nil
+	case fs.NArg() == 1 && fs.Arg(0) == "systemd":
+		return nil
+	}
+	return usage
+}
+
+func flagGiven(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	return set
+}
+
+// serviceUser is who the daemon runs as: the current user, or the one who
+// ran sudo for a system service.
+func serviceUser(userUnit bool) (*user.User, error) {
+	if name := os.Getenv("SUDO_USER"); name != "" && !userUnit {
+		return user.Lookup(name)
+	}
+	return user.Current()
+}
+
+func unitPath(userUnit bool) (string, error) {
+	if !userUnit {
+		return filepath.Join("/etc/systemd/system", unitName), nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "systemd", "user", unitName), nil
+}
+
+// systemdUnit renders the service. The daemon keeps the PATH it is
+// installed with, so that commands resolve as they do in the shell, and
+// gets its cgroup delegated for resource limits.
+func systemdUnit(execStart []string, account *user.User, userUnit bool) string {
+	quoted := make([]string, len(execStart))
+	for i, arg := range execStart {
+		// ExecStart expands variables, Environment doesn't
+		quoted[i] = systemdQuote(strings.ReplaceAll(arg, "$", "$$"))
+	}
+
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=gopm process manager\n")
+	b.WriteString("After=network.target\n")
+	b.WriteString("\n[Service]\n")
+	b.WriteString("Type=simple\n")
+	if !userUnit {
+		fmt.Fprintf(&b, "User=%s\n", account.Username)
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote("HOME="+account.HomeDir))
+		fmt.Fprintf(&b, "WorkingDirectory=%s\n", systemdQuote(account.HomeDir))
+	}
+	fmt.Fprintf(&b, "Environment=%s\n", systemdQuote("PATH="+os.Getenv("PATH")))
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(quoted, " "))
+	b.WriteString("Restart=on-failure\n")
+	b.WriteString("RestartSec=2s\n")
+	b.WriteString("Delegate=yes\n")
+	b.WriteString("\n[Install]\n")
+	if userUnit {
+		b.WriteString("WantedBy=default.target\n")
+	} else {
+		b.WriteString("WantedBy=multi-user.target\n")
+	}
+	return b.String()
+}
+
+// systemdQuote quotes a value of a unit file, escaping the specifiers
+// systemd would otherwise expand.
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// systemctl runs a systemctl command, or prints it when it can't, e.g. in a
+// container without systemd.
+func systemctl(userUnit bool, args ...string) {
+	if userUnit {
+		args = append([]string{"--user"}, args...)
+	}
+	cmd := exec.Command("systemctl", args...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: systemctl %s: %v, run it yourself\n", strings.Join(args, " "), err)
+	}
+}
+
+func unitError(err error, userUnit bool) error {
+	if errors.Is(err, os.ErrPermission) && !userUnit {
+		return fmt.Errorf("%v, run with sudo or use --user", err)
+	}
+	return err
+}
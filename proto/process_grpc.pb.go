@@ -19,11 +19,21 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ProcessManager_StartProcess_FullMethodName  = "/processmanager.ProcessManager/StartProcess"
-	ProcessManager_StopProcess_FullMethodName   = "/processmanager.ProcessManager/StopProcess"
-	ProcessManager_ListProcess_FullMethodName   = "/processmanager.ProcessManager/ListProcess"
-	ProcessManager_StreamLogs_FullMethodName    = "/processmanager.ProcessManager/StreamLogs"
-	ProcessManager_RemoveProcess_FullMethodName = "/processmanager.ProcessManager/RemoveProcess"
+	ProcessManager_StartProcess_FullMethodName    = "/processmanager.ProcessManager/StartProcess"
+	ProcessManager_StopProcess_FullMethodName     = "/processmanager.ProcessManager/StopProcess"
+	ProcessManager_ListProcess_FullMethodName     = "/processmanager.ProcessManager/ListProcess"
+	ProcessManager_StreamLogs_FullMethodName      = "/processmanager.ProcessManager/StreamLogs"
+	ProcessManager_RemoveProcess_FullMethodName   = "/processmanager.ProcessManager/RemoveProcess"
+	ProcessManager_Apply_FullMethodName           = "/processmanager.ProcessManager/Apply"
+	ProcessManager_ScaleProcess_FullMethodName    = "/processmanager.ProcessManager/ScaleProcess"
+	ProcessManager_RestartProcess_FullMethodName  = "/processmanager.ProcessManager/RestartProcess"
+	ProcessManager_ScheduleJob_FullMethodName     = "/processmanager.ProcessManager/ScheduleJob"
+	ProcessManager_RunTask_FullMethodName         = "/processmanager.ProcessManager/RunTask"
+	ProcessManager_StreamEvents_FullMethodName    = "/processmanager.ProcessManager/StreamEvents"
+	ProcessManager_Attach_FullMethodName          = "/processmanager.ProcessManager/Attach"
+	ProcessManager_SignalProcess_FullMethodName   = "/processmanager.ProcessManager/SignalProcess"
+	ProcessManager_WriteStdin_FullMethodName      = "/processmanager.ProcessManager/WriteStdin"
+	ProcessManager_DescribeProcess_FullMethodName = "/processmanager.ProcessManager/DescribeProcess"
 )
 
 // ProcessManagerClient is the client API for ProcessManager service.
@@ -35,6 +45,23 @@ type ProcessManagerClient interface {
 	ListProcess(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
 	StreamLogs(ctx context.Context, in *LogRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LogLine], error)
 	RemoveProcess(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*ProcessResponse, error)
+	Apply(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (*ProcessResponse, error)
+	ScaleProcess(ctx context.Context, in *ScaleRequest, opts ...grpc.CallOption) (*ProcessResponse, error)
+	// streams one response per rollout step
+	RestartProcess(ctx context.Context, in *RestartRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ProcessResponse], error)
+	ScheduleJob(ctx context.Context, in *JobSpec, opts ...grpc.CallOption) (*ProcessResponse, error)
+	// with wait set, streams the task's output and ends with its result
+	RunTask(ctx context.Context, in *RunTaskRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TaskOutput], error)
+	StreamEvents(ctx context.Context, in *EventRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+	// connects to the terminal of a process started with tty; the first
+	// request names the process, later ones carry input and window sizes
+	Attach(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[AttachRequest, AttachOutput], error)
+	SignalProcess(ctx context.Context, in *SignalRequest, opts ...grpc.CallOption) (*ProcessResponse, error)
+	// writes to the stdin of a process started with stdin or tty; the name
+	// is taken from the first request
+	WriteStdin(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[StdinRequest, ProcessResponse], error)
+	// everything known about one process, for debugging
+	DescribeProcess(ctx context.Context, in *DescribeRequest, opts ...grpc.CallOption) (*ProcessDescription, error)
 }
 
 type processManagerClient struct {
@@ -104,6 +131,139 @@ func (c *processManagerClient) RemoveProcess(ctx context.Context, in *RemoveRequ
 	return out, nil
 }
 
+func (c *processManagerClient) Apply(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (*ProcessResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProcessResponse)
+	err := c.cc.Invoke(ctx, ProcessManager_Apply_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *processManagerClient) ScaleProcess(ctx context.Context, in *ScaleRequest, opts ...grpc.CallOption) (*ProcessResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProcessResponse)
+	err := c.cc.Invoke(ctx, ProcessManager_ScaleProcess_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *processManagerClient) RestartProcess(ctx context.Context, in *RestartRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ProcessResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ProcessManager_ServiceDesc.Streams[1], ProcessManager_RestartProcess_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RestartRequest, ProcessResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ProcessManager_RestartProcessClient = grpc.ServerStreamingClient[ProcessResponse]
+
+func (c *processManagerClient) ScheduleJob(ctx context.Context, in *JobSpec, opts ...grpc.CallOption) (*ProcessResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProcessResponse)
+	err := c.cc.Invoke(ctx, ProcessManager_ScheduleJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *processManagerClient) RunTask(ctx context.Context, in *RunTaskRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TaskOutput], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ProcessManager_ServiceDesc.Streams[2], ProcessManager_RunTask_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RunTaskRequest, TaskOutput]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ProcessManager_RunTaskClient = grpc.ServerStreamingClient[TaskOutput]
+
+func (c *processManagerClient) StreamEvents(ctx context.Context, in *EventRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ProcessManager_ServiceDesc.Streams[3], ProcessManager_StreamEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[EventRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ProcessManager_StreamEventsClient = grpc.ServerStreamingClient[Event]
+
+func (c *processManagerClient) Attach(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[AttachRequest, AttachOutput], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ProcessManager_ServiceDesc.Streams[4], ProcessManager_Attach_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[AttachRequest, AttachOutput]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ProcessManager_AttachClient = grpc.BidiStreamingClient[AttachRequest, AttachOutput]
+
+func (c *processManagerClient) SignalProcess(ctx context.Context, in *SignalRequest, opts ...grpc.CallOption) (*ProcessResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProcessResponse)
+	err := c.cc.Invoke(ctx, ProcessManager_SignalProcess_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *processManagerClient) WriteStdin(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[StdinRequest, ProcessResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ProcessManager_ServiceDesc.Streams[5], ProcessManager_WriteStdin_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StdinRequest, ProcessResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ProcessManager_WriteStdinClient = grpc.ClientStreamingClient[StdinRequest, ProcessResponse]
+
+func (c *processManagerClient) DescribeProcess(ctx context.Context, in *DescribeRequest, opts ...grpc.CallOption) (*ProcessDescription, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProcessDescription)
+	err := c.cc.Invoke(ctx, ProcessManager_DescribeProcess_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ProcessManagerServer is the server API for ProcessManager service.
 // All implementations must embed UnimplementedProcessManagerServer
 // for forward compatibility.
@@ -113,6 +273,23 @@ type ProcessManagerServer interface {
 	ListProcess(context.Context, *ListRequest) (*ListResponse, error)
 	StreamLogs(*LogRequest, grpc.ServerStreamingServer[LogLine]) error
 	RemoveProcess(context.Context, *RemoveRequest) (*ProcessResponse, error)
+	Apply(context.Context, *ApplyRequest) (*ProcessResponse, error)
+	ScaleProcess(context.Context, *ScaleRequest) (*ProcessResponse, error)
+	// streams one response per rollout step
+	RestartProcess(*RestartRequest, grpc.ServerStreamingServer[ProcessResponse]) error
+	ScheduleJob(context.Context, *JobSpec) (*ProcessResponse, error)
+	// with wait set, streams the task's output and ends with its result
+	RunTask(*RunTaskRequest, grpc.ServerStreamingServer[TaskOutput]) error
+	StreamEvents(*EventRequest, grpc.ServerStreamingServer[Event]) error
+	// connects to the terminal of a process started with tty; the first
+	// request names the process, later ones carry input and window sizes
+	Attach(grpc.BidiStreamingServer[AttachRequest, AttachOutput]) error
+	SignalProcess(context.Context, *SignalRequest) (*ProcessResponse, error)
+	// writes to the stdin of a process started with stdin or tty; the name
+	// is taken from the first request
+	WriteStdin(grpc.ClientStreamingServer[StdinRequest, ProcessResponse]) error
+	// everything known about one process, for debugging
+	DescribeProcess(context.Context, *DescribeRequest) (*ProcessDescription, error)
 	mustEmbedUnimplementedProcessManagerServer()
 }
 
@@ -138,6 +315,36 @@ func (UnimplementedProcessManagerServer) StreamLogs(*LogRequest, grpc.ServerStre
 func (UnimplementedProcessManagerServer) RemoveProcess(context.Context, *RemoveRequest) (*ProcessResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RemoveProcess not implemented")
 }
+func (UnimplementedProcessManagerServer) Apply(context.Context, *ApplyRequest) (*ProcessResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Apply not implemented")
+}
+func (UnimplementedProcessManagerServer) ScaleProcess(context.Context, *ScaleRequest) (*ProcessResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ScaleProcess not implemented")
+}
+func (UnimplementedProcessManagerServer) RestartProcess(*RestartRequest, grpc.ServerStreamingServer[ProcessResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method RestartProcess not implemented")
+}
+func (UnimplementedProcessManagerServer) ScheduleJob(context.Context, *JobSpec) (*ProcessResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ScheduleJob not implemented")
+}
+func (UnimplementedProcessManagerServer) RunTask(*RunTaskRequest, grpc.ServerStreamingServer[TaskOutput]) error {
+	return status.Errorf(codes.Unimplemented, "method RunTask not implemented")
+}
+func (UnimplementedProcessManagerServer) StreamEvents(*EventRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEvents not implemented")
+}
+func (UnimplementedProcessManagerServer) Attach(grpc.BidiStreamingServer[AttachRequest, AttachOutput]) error {
+	return status.Errorf(codes.Unimplemented, "method Attach not implemented")
+}
+func (UnimplementedProcessManagerServer) SignalProcess(context.Context, *SignalRequest) (*ProcessResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SignalProcess not implemented")
+}
+func (UnimplementedProcessManagerServer) WriteStdin(grpc.ClientStreamingServer[StdinRequest, ProcessResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method WriteStdin not implemented")
+}
+func (UnimplementedProcessManagerServer) DescribeProcess(context.Context, *DescribeRequest) (*ProcessDescription, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DescribeProcess not implemented")
+}
 func (UnimplementedProcessManagerServer) mustEmbedUnimplementedProcessManagerServer() {}
 func (UnimplementedProcessManagerServer) testEmbeddedByValue()                        {}
 
@@ -242,6 +449,143 @@ func _ProcessManager_RemoveProcess_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ProcessManager_Apply_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApplyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessManagerServer).Apply(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProcessManager_Apply_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessManagerServer).Apply(ctx, req.(*ApplyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProcessManager_ScaleProcess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScaleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessManagerServer).ScaleProcess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProcessManager_ScaleProcess_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessManagerServer).ScaleProcess(ctx, req.(*ScaleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProcessManager_RestartProcess_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RestartRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ProcessManagerServer).RestartProcess(m, &grpc.GenericServerStream[RestartRequest, ProcessResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ProcessManager_RestartProcessServer = grpc.ServerStreamingServer[ProcessResponse]
+
+func _ProcessManager_ScheduleJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobSpec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessManagerServer).ScheduleJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProcessManager_ScheduleJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessManagerServer).ScheduleJob(ctx, req.(*JobSpec))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProcessManager_RunTask_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RunTaskRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ProcessManagerServer).RunTask(m, &grpc.GenericServerStream[RunTaskRequest, TaskOutput]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ProcessManager_RunTaskServer = grpc.ServerStreamingServer[TaskOutput]
+
+func _ProcessManager_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(EventRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ProcessManagerServer).StreamEvents(m, &grpc.GenericServerStream[EventRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ProcessManager_StreamEventsServer = grpc.ServerStreamingServer[Event]
+
+func _ProcessManager_Attach_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ProcessManagerServer).Attach(&grpc.GenericServerStream[AttachRequest, AttachOutput]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ProcessManager_AttachServer = grpc.BidiStreamingServer[AttachRequest, AttachOutput]
+
+func _ProcessManager_SignalProcess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignalRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessManagerServer).SignalProcess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProcessManager_SignalProcess_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessManagerServer).SignalProcess(ctx, req.(*SignalRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProcessManager_WriteStdin_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ProcessManagerServer).WriteStdin(&grpc.GenericServerStream[StdinRequest, ProcessResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ProcessManager_WriteStdinServer = grpc.ClientStreamingServer[StdinRequest, ProcessResponse]
+
+func _ProcessManager_DescribeProcess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DescribeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessManagerServer).DescribeProcess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProcessManager_DescribeProcess_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessManagerServer).DescribeProcess(ctx, req.(*DescribeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ProcessManager_ServiceDesc is the grpc.ServiceDesc for ProcessManager service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -265,6 +609,26 @@ var ProcessManager_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RemoveProcess",
 			Handler:    _ProcessManager_RemoveProcess_Handler,
 		},
+		{
+			MethodName: "Apply",
+			Handler:    _ProcessManager_Apply_Handler,
+		},
+		{
+			MethodName: "ScaleProcess",
+			Handler:    _ProcessManager_ScaleProcess_Handler,
+		},
+		{
+			MethodName: "ScheduleJob",
+			Handler:    _ProcessManager_ScheduleJob_Handler,
+		},
+		{
+			MethodName: "SignalProcess",
+			Handler:    _ProcessManager_SignalProcess_Handler,
+		},
+		{
+			MethodName: "DescribeProcess",
+			Handler:    _ProcessManager_DescribeProcess_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -272,6 +636,32 @@ var ProcessManager_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _ProcessManager_StreamLogs_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "RestartProcess",
+			Handler:       _ProcessManager_RestartProcess_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "RunTask",
+			Handler:       _ProcessManager_RunTask_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _ProcessManager_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Attach",
+			Handler:       _ProcessManager_Attach_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WriteStdin",
+			Handler:       _ProcessManager_WriteStdin_Handler,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "process.proto",
 }